@@ -0,0 +1,725 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/lib/pq"
+)
+
+// maxRequestBody is the size limit of the JSON bodies sent to the API.
+const maxRequestBody = 1 << 20
+
+type apiServer struct {
+	state *state
+}
+
+type apiUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeed struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Url     string    `json:"url"`
+	SiteUrl string    `json:"site_url"`
+	AddedBy string    `json:"added_by"`
+}
+
+type apiFollow struct {
+	FeedID     uuid.UUID `json:"feed_id"`
+	Name       string    `json:"name"`
+	Url        string    `json:"url"`
+	SiteUrl    string    `json:"site_url"`
+	Folder     string    `json:"folder"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type apiPost struct {
+	ID          uuid.UUID `json:"id"`
+	PublishedAt time.Time `json:"published_at"`
+	Read        bool      `json:"read"`
+	Feed        string    `json:"feed"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+}
+
+type apiPostPage struct {
+	Posts []apiPost `json:"posts"`
+	// NextAfter is the value of the after parameter for the next page, nil
+	// on the last page.
+	NextAfter *uuid.UUID `json:"next_after"`
+}
+
+type apiSearchResult struct {
+	ID          uuid.UUID `json:"id"`
+	PublishedAt time.Time `json:"published_at"`
+	Feed        string    `json:"feed"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Rank        float32   `json:"rank"`
+	Snippet     string    `json:"snippet"`
+}
+
+type apiStarredPost struct {
+	ID          uuid.UUID `json:"id"`
+	StarredAt   time.Time `json:"starred_at"`
+	PublishedAt time.Time `json:"published_at"`
+	Feed        string    `json:"feed"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	Note        string    `json:"note"`
+}
+
+type apiSession struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func handlerServe(s *state, cmd command) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	api := &apiServer{state: s}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           api.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-s.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Serving the API on %s\n", *addr)
+	err = server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (api *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", api.handleListUsers)
+	mux.HandleFunc("POST /api/users", api.handleCreateUser)
+	mux.HandleFunc("POST /api/login", api.handleLogin)
+	mux.HandleFunc("POST /api/logout", api.authenticated(api.handleLogout))
+	mux.HandleFunc("GET /api/users/{name}/timeline", api.handleTimeline)
+	mux.HandleFunc("GET /api/feeds", api.handleListFeeds)
+	mux.HandleFunc("GET /api/follows", api.authenticated(api.handleListFollows))
+	mux.HandleFunc("POST /api/follows", api.authenticated(api.handleCreateFollow))
+	mux.HandleFunc("DELETE /api/follows", api.authenticated(api.handleDeleteFollow))
+	mux.HandleFunc("GET /api/posts", api.authenticated(api.handleListPosts))
+	mux.HandleFunc("GET /api/posts/search", api.authenticated(api.handleSearchPosts))
+	mux.HandleFunc("POST /api/posts/read", api.authenticated(api.handleMarkPostsRead))
+	mux.HandleFunc("PUT /api/posts/{id}/read", api.authenticated(api.handleMarkPostRead))
+	mux.HandleFunc("DELETE /api/posts/{id}/read", api.authenticated(api.handleMarkPostUnread))
+	mux.HandleFunc("GET /api/starred", api.authenticated(api.handleListStarred))
+	mux.HandleFunc("PUT /api/posts/{id}/star", api.authenticated(api.handleStarPost))
+	mux.HandleFunc("DELETE /api/posts/{id}/star", api.authenticated(api.handleUnstarPost))
+	return mux
+}
+
+// authenticated is the API counterpart of middlewareLoggedIn: it looks up the
+// user of the session or API token sent as "Authorization: Bearer <token>".
+// API tokens need the write scope for anything but GET requests.
+func (api *apiServer) authenticated(handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondWithError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+		scope := scopeWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = scopeRead
+		}
+		user, ok := api.authenticate(w, r, token, scope)
+		if !ok {
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// authenticate returns the user token belongs to, or responds with the
+// matching error when it doesn't grant scope.
+func (api *apiServer) authenticate(w http.ResponseWriter, r *http.Request, token string, scope string) (database.User, bool) {
+	user, err := authenticate(r.Context(), api.state, token, scope)
+	if errors.Is(err, errNotLoggedIn) || errors.Is(err, errInvalidToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return database.User{}, false
+	}
+	if errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithInternalError(w, err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (api *apiServer) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := api.state.db.GetUsers(r.Context())
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	response := make([]apiUser, 0, len(users))
+	for _, user := range users {
+		response = append(response, apiUser{ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (api *apiServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		respondWithError(w, http.StatusBadRequest, "A name is required")
+		return
+	}
+	err := validatePassword(body.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hashedPassword, err := hashPassword(body.Password)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+
+	now := time.Now()
+	user, err := api.state.db.CreateUser(r.Context(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Name:           body.Name,
+		HashedPassword: hashedPassword,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("The user %s already exists", body.Name))
+		return
+	}
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, apiUser{ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt})
+}
+
+// handleLogin opens a session for the API, the token it returns is sent
+// back as a bearer token.
+func (api *apiServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	user, err := api.state.db.GetUserByName(r.Context(), body.Name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithInternalError(w, err)
+		return
+	}
+	if err != nil || !checkPassword(user, body.Password) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token, expiresAt, err := createSession(r.Context(), api.state, user)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, apiSession{Token: token, ExpiresAt: expiresAt})
+}
+
+func (api *apiServer) handleLogout(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, _ := bearerToken(r)
+	err := api.state.db.DeleteSession(r.Context(), hashToken(token))
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiServer) handleListFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := api.state.db.GetFeeds(r.Context())
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	response := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		response = append(response, apiFeed{
+			ID:      feed.ID,
+			Name:    feed.Name,
+			Url:     feed.Url,
+			SiteUrl: feed.SiteUrl,
+			AddedBy: feed.UserName,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (api *apiServer) handleListFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedFollows, err := api.state.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	response := make([]apiFollow, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		response = append(response, apiFollow{
+			FeedID:     feedFollow.FeedID,
+			Name:       feedFollow.FeedName,
+			Url:        feedFollow.FeedUrl,
+			SiteUrl:    feedFollow.FeedSiteUrl,
+			Folder:     feedFollow.Folder,
+			FollowedAt: feedFollow.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (api *apiServer) handleCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Url string `json:"url"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if body.Url == "" {
+		respondWithError(w, http.StatusBadRequest, "A url is required")
+		return
+	}
+
+	feed, err := getFeedByUrl(r.Context(), api.state, body.Url)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Feed %s not found", body.Url))
+		return
+	}
+	now := time.Now()
+	feedFollow, err := api.state.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("You already follow %s", feed.Name))
+		return
+	}
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, apiFollow{
+		FeedID:     feed.ID,
+		Name:       feed.Name,
+		Url:        feed.Url,
+		SiteUrl:    feed.SiteUrl,
+		Folder:     feedFollow.Folder,
+		FollowedAt: feedFollow.CreatedAt,
+	})
+}
+
+func (api *apiServer) handleDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		respondWithError(w, http.StatusBadRequest, "A url is required")
+		return
+	}
+	feed, err := getFeedByUrl(r.Context(), api.state, url)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Feed %s not found", url))
+		return
+	}
+	err = api.state.db.DeleteFeedFollowsForUser(r.Context(), database.DeleteFeedFollowsForUserParams{
+		UserID:       user.ID,
+		CanonicalUrl: feed.CanonicalUrl,
+	})
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListPosts takes the same filters as the browse command as query
+// parameters: limit, page, after, feed, since, until and read, which is
+// false by default and may be true or all.
+func (api *apiServer) handleListPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	filters := postFilters{
+		feedURL: query.Get("feed"),
+		since:   query.Get("since"),
+		until:   query.Get("until"),
+		after:   query.Get("after"),
+		page:    1,
+	}
+
+	limit, ok := intParam(w, r, "limit", 20, 100)
+	if !ok {
+		return
+	}
+	filters.limit = int32(limit)
+	filters.page, ok = intParam(w, r, "page", 1, 0)
+	if !ok {
+		return
+	}
+	switch query.Get("read") {
+	case "", "false":
+	case "true":
+		filters.onlyRead = true
+	case "all":
+		filters.all = true
+	default:
+		respondWithError(w, http.StatusBadRequest, "read must be true, false or all")
+		return
+	}
+
+	params, err := filters.params(r.Context(), api.state, user)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	posts, err := api.state.db.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+
+	response := apiPostPage{Posts: make([]apiPost, 0, len(posts))}
+	for _, post := range posts {
+		response.Posts = append(response.Posts, apiPost{
+			ID:          post.ID,
+			PublishedAt: post.PublishedAt,
+			Read:        post.ReadAt.Valid,
+			Feed:        post.FeedName,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+		})
+	}
+	if len(posts) == limit {
+		response.NextAfter = &posts[len(posts)-1].ID
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleSearchPosts searches the posts of the followed feeds, or of every
+// feed when all=true, with the query syntax of the search command.
+func (api *apiServer) handleSearchPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := searchQuery(r.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(w, http.StatusBadRequest, "A search query is required")
+		return
+	}
+	limit, ok := intParam(w, r, "limit", 10, 100)
+	if !ok {
+		return
+	}
+
+	posts, err := api.state.db.SearchPosts(r.Context(), database.SearchPostsParams{
+		Query:    query,
+		AllFeeds: r.URL.Query().Get("all") == "true",
+		UserID:   user.ID,
+		Limit:    int32(limit),
+	})
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+
+	response := make([]apiSearchResult, 0, len(posts))
+	for _, post := range posts {
+		response = append(response, apiSearchResult{
+			ID:          post.ID,
+			PublishedAt: post.PublishedAt,
+			Feed:        post.FeedName,
+			Title:       post.Title,
+			Url:         post.Url,
+			Rank:        post.Rank,
+			Snippet:     strings.Join(strings.Fields(post.Snippet), " "),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (api *apiServer) handleMarkPostsRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Feed   string `json:"feed"`
+		Before string `json:"before"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	params := database.MarkPostsReadParams{
+		ReadAt: time.Now(),
+		UserID: user.ID,
+	}
+	if body.Feed != "" {
+		feed, err := getFeedByUrl(r.Context(), api.state, body.Feed)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("Feed %s not found", body.Feed))
+			return
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if body.Before != "" {
+		date, err := parseDate(body.Before)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params.Before = sql.NullTime{Time: date, Valid: true}
+	}
+
+	count, err := api.state.db.MarkPostsRead(r.Context(), params)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, struct {
+		Marked int64 `json:"marked"`
+	}{Marked: count})
+}
+
+func (api *apiServer) handleMarkPostRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, ok := postIDParam(w, r)
+	if !ok {
+		return
+	}
+	err := api.state.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		ReadAt: time.Now(),
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "post_reads_post_id_fkey" {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Post %s not found", postID))
+		return
+	}
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiServer) handleMarkPostUnread(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, ok := postIDParam(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.state.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiServer) handleListStarred(w http.ResponseWriter, r *http.Request, user database.User) {
+	starred, err := api.state.db.GetStarredPostsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	response := make([]apiStarredPost, 0, len(starred))
+	for _, post := range starred {
+		response = append(response, toAPIStarredPost(post))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (api *apiServer) handleStarPost(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, ok := postIDParam(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 && !decodeBody(w, r, &body) {
+		return
+	}
+
+	now := time.Now()
+	starred, err := api.state.db.StarPost(r.Context(), database.StarPostParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Note:      body.Note,
+		PostID:    postID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Post %s not found", postID))
+		return
+	}
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toAPIStarredPost(starred))
+}
+
+func (api *apiServer) handleUnstarPost(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, ok := postIDParam(w, r)
+	if !ok {
+		return
+	}
+	count, err := api.state.db.UnstarPost(r.Context(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: uuid.NullUUID{UUID: postID, Valid: true},
+	})
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+	if count == 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Post %s is not starred", postID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toAPIStarredPost identifies starred posts like the starred command does:
+// by the id of the post, or of the star once the post is gone.
+func toAPIStarredPost(post database.StarredPost) apiStarredPost {
+	id := post.ID
+	if post.PostID.Valid {
+		id = post.PostID.UUID
+	}
+	return apiStarredPost{
+		ID:          id,
+		StarredAt:   post.CreatedAt,
+		PublishedAt: post.PublishedAt,
+		Feed:        post.FeedName,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description,
+		Note:        post.Note,
+	}
+}
+
+// API utilities
+func respondWithJSON(w http.ResponseWriter, status int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// respondWithError sends message to the client. Errors built for the CLI
+// end with a new line, which is dropped.
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	respondWithJSON(w, status, apiError{Error: strings.TrimSpace(message)})
+}
+
+func respondWithInternalError(w http.ResponseWriter, err error) {
+	fmt.Printf("API error: %s\n", err)
+	respondWithError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, body any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	err := decoder.Decode(body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON body: %s", err))
+		return false
+	}
+	return true
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func postIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	postID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid post id %s", r.PathValue("id")))
+		return uuid.UUID{}, false
+	}
+	return postID, true
+}
+
+// intParam reads a positive integer query parameter, at most max unless max
+// is 0.
+func intParam(w http.ResponseWriter, r *http.Request, name string, fallback, max int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 || (max > 0 && parsed > max) {
+		message := fmt.Sprintf("%s must be a positive integer", name)
+		if max > 0 {
+			message = fmt.Sprintf("%s must be between 1 and %d", name, max)
+		}
+		respondWithError(w, http.StatusBadRequest, message)
+		return 0, false
+	}
+	return parsed, true
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const (
+	sessionDuration   = 30 * 24 * time.Hour
+	minPasswordLength = 8
+	// apiTokenPrefix tells API tokens apart from session tokens.
+	apiTokenPrefix = "gat_"
+)
+
+// Scopes of the API tokens. Write implies read, which implies feed. Feed
+// only allows reading the published timeline, its tokens are meant to be
+// given to feed readers.
+const (
+	scopeFeed  = "feed"
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// impliedScopes lists the scopes each scope grants besides itself.
+var impliedScopes = map[string][]string{
+	scopeRead:  {scopeFeed},
+	scopeWrite: {scopeRead, scopeFeed},
+}
+
+var (
+	errNotLoggedIn  = errors.New("You are not logged in, run 'gator login <name>' first")
+	errInvalidToken = errors.New("Invalid or expired API token")
+	errMissingScope = errors.New("The API token doesn't allow this")
+)
+
+// readOnlyCommands are the commands that only need the read scope, the
+// others need the write scope.
+var readOnlyCommands = map[string]bool{
+	"following":   true,
+	"browse":      true,
+	"search":      true,
+	"starred":     true,
+	"export-opml": true,
+	"publish":     true,
+}
+
+// stdinReader is shared by the prompts, a reader per prompt would lose what
+// it buffered past the first line.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword tells whether password matches the one user registered with.
+func checkPassword(user database.User, password string) bool {
+	if user.HashedPassword == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)) == nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("The password must be at least %d characters long\n", minPasswordLength)
+	}
+	return nil
+}
+
+// newToken returns a random token starting with prefix along with the hash
+// stored in the database, so that a leaked database doesn't leak usable
+// tokens.
+func newToken(prefix string) (string, string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+	token := prefix + hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func createSession(ctx context.Context, s *state, user database.User) (string, time.Time, error) {
+	token, tokenHash, err := newToken("")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Expired sessions are only useful until the next login.
+	err = s.db.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	session, err := s.db.CreateSession(ctx, database.CreateSessionParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(sessionDuration),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+// currentToken returns the token the CLI authenticates with: the GATOR_TOKEN
+// environment variable, or the session saved by login.
+func currentToken(s *state) string {
+	token := os.Getenv("GATOR_TOKEN")
+	if token != "" {
+		return token
+	}
+	return s.config.SessionToken
+}
+
+// authenticate returns the user token belongs to. Sessions allow everything,
+// API tokens need scope, and their last use is recorded.
+func authenticate(ctx context.Context, s *state, token string, scope string) (database.User, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return sessionUser(ctx, s, token)
+	}
+
+	row, err := s.db.GetUserByAPIToken(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errInvalidToken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if !hasScope(row.TokenScopes, scope) {
+		return database.User{}, fmt.Errorf("%w, it needs the %s scope", errMissingScope, scope)
+	}
+
+	err = s.db.TouchAPIToken(ctx, database.TouchAPITokenParams{
+		ID:         row.TokenID,
+		LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return database.User{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+		Name:           row.Name,
+		HashedPassword: row.HashedPassword,
+	}, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+		for _, implied := range impliedScopes[granted] {
+			if implied == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sessionUser returns the user token was issued to, or errNotLoggedIn when
+// the session doesn't exist or expired.
+func sessionUser(ctx context.Context, s *state, token string) (database.User, error) {
+	if token == "" {
+		return database.User{}, errNotLoggedIn
+	}
+	user, err := s.db.GetUserBySessionToken(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errNotLoggedIn
+	}
+	return user, err
+}
+
+// login creates a session for user and stores its token in the config.
+func login(s *state, user database.User) error {
+	token, expiresAt, err := createSession(s.ctx, s, user)
+	if err != nil {
+		return err
+	}
+	err = s.config.SetSession(token, expiresAt)
+	if err != nil {
+		return fmt.Errorf("Error while saving your session: %s\n", err)
+	}
+	return nil
+}
+
+// promptPassword reads a password without echoing it when stdin is a
+// terminal, or a line of stdin otherwise so that scripts can pipe it.
+func promptPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		return string(password), err
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("No password given\n")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptNewPassword asks for a password twice.
+func promptNewPassword() (string, error) {
+	password, err := promptPassword("Password: ")
+	if err != nil {
+		return "", err
+	}
+	err = validatePassword(password)
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := promptPassword("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != password {
+		return "", fmt.Errorf("The passwords don't match\n")
+	}
+	return password, nil
+}
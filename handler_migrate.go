@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/killuox/gator-blog-aggregator/internal/migrate"
+	"github.com/killuox/gator-blog-aggregator/internal/output"
+)
+
+//go:embed sql/schema/*.sql
+var schemaFiles embed.FS
+
+// newMigrator returns a migrator for the migrations embedded in the binary.
+func newMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	schema, err := fs.Sub(schemaFiles, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, schema)
+}
+
+func handlerMigrate(s *state, cmd command) error {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := formatFlag(flags)
+	args, err := parseFlags(flags, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("A subcommand is required: up, down, status or redo\n")
+	}
+
+	switch args[0] {
+	case "up":
+		migrations, err := s.migrator.Up(s.ctx)
+		for _, migration := range migrations {
+			fmt.Printf("Applied %s\n", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(migrations) == 0 {
+			fmt.Printf("The database is up to date\n")
+		}
+		return nil
+	case "down":
+		migration, err := s.migrator.Down(s.ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %s\n", migration.Name)
+		return nil
+	case "redo":
+		migration, err := s.migrator.Redo(s.ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted and applied %s again\n", migration.Name)
+		return nil
+	case "status":
+		statuses, err := s.migrator.Status(s.ctx)
+		if err != nil {
+			return err
+		}
+		list := output.NewList("version", "name", "applied_at")
+		for _, status := range statuses {
+			list.Append(status.Version, status.Name, nullTime(status.AppliedAt))
+		}
+		return output.Write(os.Stdout, *format, list)
+	}
+	return fmt.Errorf("Unknown subcommand %s, expected up, down, status or redo\n", args[0])
+}
+
+// checkSchema refuses to run commands against a database on which some
+// migrations are not applied yet.
+func checkSchema(s *state) error {
+	pending, err := s.migrator.Pending(s.ctx)
+	if err != nil {
+		return fmt.Errorf("Could not check the database schema: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("The database schema is out of date (%d pending migration(s)), run 'gator migrate up' first\n", len(pending))
+	}
+	return nil
+}
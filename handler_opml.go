@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/killuox/gator-blog-aggregator/internal/opml"
+	"github.com/killuox/gator-blog-aggregator/internal/rss"
+	"github.com/killuox/gator-blog-aggregator/internal/urlnorm"
+	"github.com/lib/pq"
+)
+
+func handlerImportOPML(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 4, "number of new feeds checked in parallel")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("An OPML file is required\n")
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("Invalid concurrency %d\n", *concurrency)
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	doc, err := opml.Parse(file)
+	if err != nil {
+		return err
+	}
+	subs := doc.Subscriptions()
+
+	// Feeds that don't exist yet are fetched first, so that dead or invalid
+	// subscriptions are reported instead of being created.
+	newFeeds := map[string]string{}
+	for _, sub := range subs {
+		url, err := urlnorm.Clean(sub.XMLURL)
+		if err != nil {
+			continue
+		}
+		canonicalUrl, err := urlnorm.Key(url)
+		if err != nil || newFeeds[canonicalUrl] != "" {
+			continue
+		}
+		_, err = s.db.GetFeedByUrl(s.ctx, canonicalUrl)
+		if errors.Is(err, sql.ErrNoRows) {
+			newFeeds[canonicalUrl] = url
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(newFeeds) > 0 {
+		fmt.Printf("Checking %d new feed(s)...\n", len(newFeeds))
+	}
+	checks := checkFeeds(s, newFeeds, *concurrency)
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+
+	feedFollows, err := s.db.GetFeedFollowsForUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	following := map[uuid.UUID]bool{}
+	for _, feedFollow := range feedFollows {
+		following[feedFollow.FeedID] = true
+	}
+
+	var added, present, invalid int
+	for _, sub := range subs {
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+
+		if sub.XMLURL == "" {
+			invalid++
+			fmt.Printf("! %s: no feed url\n", sub.Title)
+			continue
+		}
+		feed, err := findOrCreateFeed(s, user, sub, checks)
+		if err != nil {
+			invalid++
+			fmt.Printf("! %s (%s): %s\n", sub.Title, sub.XMLURL, err)
+			continue
+		}
+
+		if following[feed.ID] {
+			present++
+			fmt.Printf("= %s (%s)\n", feed.Name, feed.Url)
+			continue
+		}
+
+		_, err = s.db.CreateFeedFollow(s.ctx, database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if sub.Folder != "" {
+			err = s.db.SetFeedFollowFolder(s.ctx, database.SetFeedFollowFolderParams{
+				UserID: user.ID,
+				FeedID: feed.ID,
+				Folder: sub.Folder,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		following[feed.ID] = true
+		added++
+		fmt.Printf("+ %s (%s)\n", feed.Name, feed.Url)
+	}
+
+	fmt.Printf("Imported %s: %d added, %d already present, %d invalid\n", args[0], added, present, invalid)
+	return nil
+}
+
+// feedCheck is the outcome of fetching a feed before creating it.
+type feedCheck struct {
+	feed *rss.RSSFeed
+	err  error
+}
+
+// checkFeeds fetches urls, keyed by their canonical url, with at most
+// concurrency requests in flight.
+func checkFeeds(s *state, urls map[string]string, concurrency int) map[string]feedCheck {
+	checks := make(map[string]feedCheck, len(urls))
+	var mu sync.Mutex
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for canonicalUrl := range jobs {
+				feed, err := s.fetcher.FetchFeed(s.ctx, urls[canonicalUrl])
+				mu.Lock()
+				checks[canonicalUrl] = feedCheck{feed: feed, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for canonicalUrl := range urls {
+		jobs <- canonicalUrl
+	}
+	close(jobs)
+	wg.Wait()
+	return checks
+}
+
+// findOrCreateFeed returns the feed of sub, creating it when no feed has the
+// same canonical url yet and checks tells it is a valid feed. Its posts are
+// stored by agg.
+func findOrCreateFeed(s *state, user database.User, sub opml.Subscription, checks map[string]feedCheck) (database.Feed, error) {
+	url, err := urlnorm.Clean(sub.XMLURL)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	canonicalUrl, err := urlnorm.Key(url)
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	feed, err := s.db.GetFeedByUrl(s.ctx, canonicalUrl)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return feed, err
+	}
+
+	check, ok := checks[canonicalUrl]
+	if !ok {
+		return database.Feed{}, fmt.Errorf("The feed was not checked")
+	}
+	if check.err != nil {
+		return database.Feed{}, fmt.Errorf("Not a valid feed: %w", check.err)
+	}
+
+	name := sub.Title
+	if name == "" {
+		name = strings.TrimSpace(check.feed.Channel.Title)
+	}
+	if name == "" {
+		name = canonicalUrl
+	}
+	siteUrl, err := urlnorm.Clean(sub.HTMLURL)
+	if err != nil {
+		siteUrl = strings.TrimSpace(check.feed.Channel.Link)
+	}
+
+	params := database.CreateFeedParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         name,
+		Url:          url,
+		CanonicalUrl: canonicalUrl,
+		UserID:       user.ID,
+		Description:  strings.TrimSpace(check.feed.Channel.Description),
+		SiteUrl:      siteUrl,
+	}
+	feed, err = s.db.CreateFeed(s.ctx, params)
+
+	// Feed names are unique, tell homonyms apart with their url.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "feeds_name_key" {
+		params.Name = fmt.Sprintf("%s (%s)", name, canonicalUrl)
+		feed, err = s.db.CreateFeed(s.ctx, params)
+	}
+	return feed, err
+}
+
+func handlerExportOPML(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	output := fs.String("output", "", "file to write the OPML document to instead of stdout")
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	feedFollows, err := s.db.GetFeedFollowsForUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	subscriptions := make([]opml.Subscription, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		subscriptions = append(subscriptions, opml.Subscription{
+			Title:       feedFollow.FeedName,
+			XMLURL:      feedFollow.FeedUrl,
+			HTMLURL:     feedFollow.FeedSiteUrl,
+			Description: feedFollow.FeedDescription,
+			Folder:      feedFollow.Folder,
+		})
+	}
+	doc := opml.New(fmt.Sprintf("%s's gator subscriptions", user.Name), subscriptions)
+
+	if *output == "" {
+		return doc.Write(os.Stdout)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = doc.Write(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d feed(s) to %s\n", len(subscriptions), *output)
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/killuox/gator-blog-aggregator/internal/publish"
+)
+
+func handlerPublish(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := fs.String("format", "rss", "document format: rss or atom")
+	output := fs.String("output", "", "file to write the document to instead of stdout")
+	limit := fs.Int("limit", 50, "maximum number of posts")
+	link := fs.String("link", "", "URL the document is published at")
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return fmt.Errorf("Invalid limit %d\n", *limit)
+	}
+	if *format != "rss" && *format != "atom" {
+		return fmt.Errorf("Unknown format %s, expected rss or atom\n", *format)
+	}
+
+	timeline, err := userTimeline(s.ctx, s, user, *limit, *link)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return writeTimeline(os.Stdout, *format, timeline)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = writeTimeline(file, *format, timeline)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Published %d post(s) to %s\n", len(timeline.Entries), *output)
+	return nil
+}
+
+// handleTimeline serves the timeline of the user named in the path. Feed
+// readers can't send headers, so besides a bearer token it takes an API
+// token in the token query parameter, preferably one with only the feed
+// scope. It also takes the format (rss by default, or atom) and limit query
+// parameters.
+func (api *apiServer) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondWithError(w, http.StatusUnauthorized, "Missing bearer token or token parameter")
+		return
+	}
+	if !ok && !strings.HasPrefix(token, apiTokenPrefix) {
+		// URLs end up in logs and histories, sessions must not.
+		respondWithError(w, http.StatusUnauthorized, "The token parameter only takes API tokens")
+		return
+	}
+	user, ok := api.authenticate(w, r, token, scopeFeed)
+	if !ok {
+		return
+	}
+	if user.Name != r.PathValue("name") {
+		respondWithError(w, http.StatusForbidden, "The token doesn't belong to this user")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "rss"
+	}
+	if format != "rss" && format != "atom" {
+		respondWithError(w, http.StatusBadRequest, "format must be rss or atom")
+		return
+	}
+	limit, ok := intParam(w, r, "limit", 50, 500)
+	if !ok {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	link := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.RequestURI())
+
+	timeline, err := userTimeline(r.Context(), api.state, user, limit, link)
+	if err != nil {
+		respondWithInternalError(w, err)
+		return
+	}
+
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == "atom" {
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	err = writeTimeline(w, format, timeline)
+	if err != nil {
+		fmt.Printf("API error: %s\n", err)
+	}
+}
+
+// userTimeline merges the latest posts of the feeds user follows. Posts
+// found in several feeds are only listed once.
+func userTimeline(ctx context.Context, s *state, user database.User, limit int, link string) (publish.Timeline, error) {
+	posts, err := s.db.GetTimelineForUser(ctx, database.GetTimelineForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return publish.Timeline{}, err
+	}
+
+	timeline := publish.Timeline{
+		ID:          "urn:uuid:" + user.ID.String(),
+		Title:       fmt.Sprintf("%s's gator timeline", user.Name),
+		Description: fmt.Sprintf("Latest posts of the feeds %s follows", user.Name),
+		Link:        link,
+		Updated:     user.CreatedAt,
+	}
+	for _, post := range posts {
+		timeline.Entries = append(timeline.Entries, publish.Entry{
+			ID:          "urn:uuid:" + post.ID.String(),
+			Title:       post.Title,
+			Link:        post.Url,
+			Description: post.Description,
+			Published:   post.PublishedAt,
+			Updated:     post.UpdatedAt,
+			SourceTitle: post.FeedName,
+			SourceURL:   post.FeedUrl,
+		})
+		if post.UpdatedAt.After(timeline.Updated) {
+			timeline.Updated = post.UpdatedAt
+		}
+		if post.PublishedAt.After(timeline.Updated) {
+			timeline.Updated = post.PublishedAt
+		}
+	}
+	return timeline, nil
+}
+
+func writeTimeline(w io.Writer, format string, timeline publish.Timeline) error {
+	if format == "atom" {
+		return publish.WriteAtom(w, timeline)
+	}
+	return publish.WriteRSS(w, timeline)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/killuox/gator-blog-aggregator/internal/output"
+)
+
+func handlerSearch(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	all := fs.Bool("all", false, "search the posts of every feed, not only the followed ones")
+	limit := fs.Int("limit", 10, "maximum number of posts to show")
+	format := formatFlag(fs)
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return fmt.Errorf("Invalid limit %d\n", *limit)
+	}
+
+	query := searchQuery(strings.Join(args, " "))
+	if query == "" {
+		return fmt.Errorf("A search query is required\n")
+	}
+
+	posts, err := s.db.SearchPosts(s.ctx, database.SearchPostsParams{
+		Query:    query,
+		AllFeeds: *all,
+		UserID:   user.ID,
+		Limit:    int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 && *format == output.Table {
+		fmt.Printf("No posts found\n")
+		return nil
+	}
+
+	list := output.NewList("id", "published_at", "feed", "title", "url", "rank", "snippet")
+	for _, post := range posts {
+		snippet := strings.Join(strings.Fields(post.Snippet), " ")
+		list.Append(post.ID, post.PublishedAt, post.FeedName, post.Title, post.Url, post.Rank, snippet)
+	}
+	return output.Write(os.Stdout, *format, list)
+}
+
+// searchQuery turns what the user typed into a to_tsquery expression. Every
+// word has to match, as a prefix unless it is part of a "quoted phrase", whose
+// words have to follow each other. Only letters and digits are kept, so the
+// result is always a valid expression.
+func searchQuery(input string) string {
+	var terms []string
+	for idx, chunk := range strings.Split(input, `"`) {
+		// Odd chunks are the ones between quotes.
+		if idx%2 == 1 {
+			terms = append(terms, phraseQuery(lexemes(chunk)))
+			continue
+		}
+		for _, word := range strings.Fields(chunk) {
+			words := lexemes(word)
+			if len(words) == 1 {
+				terms = append(terms, words[0]+":*")
+				continue
+			}
+			terms = append(terms, phraseQuery(words))
+		}
+	}
+
+	var parts []string
+	for _, term := range terms {
+		if term != "" {
+			parts = append(parts, term)
+		}
+	}
+	return strings.Join(parts, " & ")
+}
+
+func lexemes(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func phraseQuery(words []string) string {
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
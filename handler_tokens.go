@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/killuox/gator-blog-aggregator/internal/output"
+)
+
+func handlerToken(s *state, cmd command, user database.User) error {
+	// A leaked token must not be able to mint new ones or hide its tracks.
+	if strings.HasPrefix(currentToken(s), apiTokenPrefix) {
+		return fmt.Errorf("API tokens can't manage API tokens, log in with 'gator login' instead\n")
+	}
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("A subcommand is required: create, list or revoke\n")
+	}
+
+	sub := command{name: cmd.name + " " + cmd.args[0], args: cmd.args[1:]}
+	switch cmd.args[0] {
+	case "create":
+		return handlerTokenCreate(s, sub, user)
+	case "list":
+		return handlerTokenList(s, sub, user)
+	case "revoke":
+		return handlerTokenRevoke(s, sub, user)
+	}
+	return fmt.Errorf("Unknown subcommand %s, expected create, list or revoke\n", cmd.args[0])
+}
+
+func handlerTokenCreate(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	scope := fs.String("scope", scopeRead, "comma separated scopes: feed, read, write")
+	expires := fs.String("expires", "", "lifetime (e.g. 90d or 12h) or expiry date, never expires when empty")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("A token name is required\n")
+	}
+	name := args[0]
+
+	scopes, err := parseScopes(*scope)
+	if err != nil {
+		return err
+	}
+	var expiresAt sql.NullTime
+	if *expires != "" {
+		date, err := parseExpiry(*expires)
+		if err != nil {
+			return err
+		}
+		expiresAt = sql.NullTime{Time: date, Valid: true}
+	}
+
+	token, tokenHash, err := newToken(apiTokenPrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.CreateAPIToken(s.ctx, database.CreateAPITokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("You already have a token named %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created token %s with scopes %s. It won't be shown again:\n", name, strings.Join(scopes, ","))
+	fmt.Printf("%s\n", token)
+	return nil
+}
+
+func handlerTokenList(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := formatFlag(fs)
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.db.GetAPITokensForUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	list := output.NewList("name", "scopes", "created_at", "expires_at", "last_used_at")
+	for _, token := range tokens {
+		list.Append(token.Name, strings.Join(token.Scopes, ","), token.CreatedAt, nullTime(token.ExpiresAt), nullTime(token.LastUsedAt))
+	}
+	return output.Write(os.Stdout, *format, list)
+}
+
+func handlerTokenRevoke(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("A token name is required\n")
+	}
+	name := cmd.args[0]
+
+	count, err := s.db.DeleteAPIToken(s.ctx, database.DeleteAPITokenParams{
+		UserID: user.ID,
+		Name:   name,
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("You have no token named %s\n", name)
+	}
+	fmt.Printf("Revoked token %s\n", name)
+	return nil
+}
+
+func parseScopes(value string) ([]string, error) {
+	seen := map[string]bool{}
+	var scopes []string
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope != scopeFeed && scope != scopeRead && scope != scopeWrite {
+			return nil, fmt.Errorf("Unknown scope %q, expected feed, read or write\n", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// parseExpiry reads a token lifetime in days (90d) or as a Go duration
+// (12h), or the date it expires on.
+func parseExpiry(value string) (time.Time, error) {
+	days, ok := strings.CutSuffix(value, "d")
+	if ok {
+		count, err := strconv.Atoi(days)
+		if err == nil && count > 0 {
+			return time.Now().AddDate(0, 0, count), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration > 0 {
+		return time.Now().Add(duration), nil
+	}
+	date, err := parseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid expiry %s, expected a lifetime such as 90d or 12h, or a date\n", value)
+	}
+	if !date.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("The expiry date %s is in the past\n", value)
+	}
+	return date, nil
+}
@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 const fileName = ".gatorconfig.json"
 
 type Config struct {
-	DbUrl           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DbUrl string `json:"db_url"`
+	// SessionToken authenticates the logged in user until SessionExpiresAt.
+	SessionToken     string    `json:"session_token,omitempty"`
+	SessionExpiresAt time.Time `json:"session_expires_at"`
 }
 
 func Read() (Config, error) {
@@ -32,22 +35,29 @@ func Read() (Config, error) {
 	return configData, nil
 }
 
-func (c *Config) SetUser(username string) error {
+func (c *Config) SetSession(token string, expiresAt time.Time) error {
 	configData, err := Read()
 	if err != nil {
 		return err
 	}
 
-	configData.CurrentUserName = username
+	configData.SessionToken = token
+	configData.SessionExpiresAt = expiresAt
 
 	err = write(configData)
 	if err != nil {
 		return err
 	}
 
+	c.SessionToken = token
+	c.SessionExpiresAt = expiresAt
 	return nil
 }
 
+func (c *Config) ClearSession() error {
+	return c.SetSession("", time.Time{})
+}
+
 // Private functions
 func getConfigFilePath() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -69,7 +79,12 @@ func write(cfg Config) error {
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(filePath, jsonData, 0644)
+	// The file holds the session token, only its owner may read it.
+	err = os.WriteFile(filePath, jsonData, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(filePath, 0600)
 	if err != nil {
 		return err
 	}
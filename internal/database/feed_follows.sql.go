@@ -22,11 +22,11 @@ WITH inserted_feed_follow AS (
         $4,
         $5
     )
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, folder
 )
 
 SELECT 
-    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.folder,
     feeds.name AS feed_name,
     users.name AS user_name
 FROM inserted_feed_follow
@@ -48,6 +48,7 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    string
 	FeedName  string
 	UserName  string
 }
@@ -67,6 +68,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Folder,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -78,38 +80,46 @@ DELETE FROM feed_follows
 USING feeds
 WHERE feed_follows.feed_id = feeds.id
   AND feed_follows.user_id = $1
-  AND feeds.url = $2
+  AND feeds.canonical_url = $2
 `
 
 type DeleteFeedFollowsForUserParams struct {
-	UserID uuid.UUID
-	Url    string
+	UserID       uuid.UUID
+	CanonicalUrl string
 }
 
 func (q *Queries) DeleteFeedFollowsForUser(ctx context.Context, arg DeleteFeedFollowsForUserParams) error {
-	_, err := q.db.ExecContext(ctx, deleteFeedFollowsForUser, arg.UserID, arg.Url)
+	_, err := q.db.ExecContext(ctx, deleteFeedFollowsForUser, arg.UserID, arg.CanonicalUrl)
 	return err
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT 
-    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
+    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.folder,
     feeds.name AS feed_name,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.url AS feed_url,
+    feeds.site_url AS feed_site_url,
+    feeds.description AS feed_description
 FROM feed_follows
 INNER JOIN users ON users.id = feed_follows.user_id
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.folder, feeds.name
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          uuid.UUID
+	FeedID          uuid.UUID
+	Folder          string
+	FeedName        string
+	UserName        string
+	FeedUrl         string
+	FeedSiteUrl     string
+	FeedDescription string
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -127,8 +137,12 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Folder,
 			&i.FeedName,
 			&i.UserName,
+			&i.FeedUrl,
+			&i.FeedSiteUrl,
+			&i.FeedDescription,
 		); err != nil {
 			return nil, err
 		}
@@ -142,3 +156,21 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const setFeedFollowFolder = `-- name: SetFeedFollowFolder :exec
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+WHERE feed_follows.user_id = $1
+  AND feed_follows.feed_id = $2
+`
+
+type SetFeedFollowFolderParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+	Folder string
+}
+
+func (q *Queries) SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFollowFolder, arg.UserID, arg.FeedID, arg.Folder)
+	return err
+}
@@ -7,31 +7,83 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimNextFeedToFetch = `-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = $1::timestamptz, updated_at = $1::timestamptz
+WHERE feeds.id = (
+    SELECT id FROM feeds
+    WHERE (last_fetched_at IS NULL OR last_fetched_at < $2::timestamptz)
+      AND (retry_after IS NULL OR retry_after <= $1::timestamptz)
+      AND disabled_at IS NULL
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, last_succeeded_at, retry_after, disabled_at, canonical_url, description, site_url
+`
+
+type ClaimNextFeedToFetchParams struct {
+	FetchedAt   time.Time
+	StaleBefore time.Time
+}
+
+func (q *Queries) ClaimNextFeedToFetch(ctx context.Context, arg ClaimNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeedToFetch, arg.FetchedAt, arg.StaleBefore)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSucceededAt,
+		&i.RetryAfter,
+		&i.DisabledAt,
+		&i.CanonicalUrl,
+		&i.Description,
+		&i.SiteUrl,
+	)
+	return i, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, canonical_url, user_id, description, site_url)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8,
+    $9
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, last_succeeded_at, retry_after, disabled_at, canonical_url, description, site_url
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	Url          string
+	CanonicalUrl string
+	UserID       uuid.UUID
+	Description  string
+	SiteUrl      string
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -41,7 +93,10 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.UpdatedAt,
 		arg.Name,
 		arg.Url,
+		arg.CanonicalUrl,
 		arg.UserID,
+		arg.Description,
+		arg.SiteUrl,
 	)
 	var i Feed
 	err := row.Scan(
@@ -52,17 +107,27 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSucceededAt,
+		&i.RetryAfter,
+		&i.DisabledAt,
+		&i.CanonicalUrl,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-WHERE feeds.url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, last_succeeded_at, retry_after, disabled_at, canonical_url, description, site_url FROM feeds
+WHERE feeds.canonical_url = $1
 `
 
-func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getFeedByUrl, url)
+func (q *Queries) GetFeedByUrl(ctx context.Context, canonicalUrl string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByUrl, canonicalUrl)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -72,18 +137,77 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastSucceededAt,
+		&i.RetryAfter,
+		&i.DisabledAt,
+		&i.CanonicalUrl,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
+const getFeedStatuses = `-- name: GetFeedStatuses :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, last_succeeded_at, retry_after, disabled_at, canonical_url, description, site_url FROM feeds
+ORDER BY disabled_at ASC NULLS FIRST, consecutive_failures DESC, name
+`
+
+func (q *Queries) GetFeedStatuses(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedStatuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.LastSucceededAt,
+			&i.RetryAfter,
+			&i.DisabledAt,
+			&i.CanonicalUrl,
+			&i.Description,
+			&i.SiteUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name AS user_name FROM feeds
+SELECT feeds.id, feeds.name, feeds.url, feeds.site_url, users.name AS user_name FROM feeds
 INNER JOIN users ON users.id = feeds.user_id
+ORDER BY feeds.name
 `
 
 type GetFeedsRow struct {
+	ID       uuid.UUID
 	Name     string
 	Url      string
+	SiteUrl  string
 	UserName string
 }
 
@@ -96,7 +220,13 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.SiteUrl,
+			&i.UserName,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -110,29 +240,6 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY
-    last_fetched_at ASC NULLS FIRST,
-    last_fetched_at ASC
-LIMIT 1
-`
-
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i Feed
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Name,
-		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
-	)
-	return i, err
-}
-
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $1, updated_at = $1
@@ -148,3 +255,70 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.ID)
 	return err
 }
+
+const recordFeedFetchFailure = `-- name: RecordFeedFetchFailure :exec
+UPDATE feeds
+SET last_error = $2, consecutive_failures = consecutive_failures + 1, retry_after = $3, disabled_at = $4
+WHERE feeds.id = $1
+`
+
+type RecordFeedFetchFailureParams struct {
+	ID         uuid.UUID
+	LastError  string
+	RetryAfter sql.NullTime
+	DisabledAt sql.NullTime
+}
+
+func (q *Queries) RecordFeedFetchFailure(ctx context.Context, arg RecordFeedFetchFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFetchFailure,
+		arg.ID,
+		arg.LastError,
+		arg.RetryAfter,
+		arg.DisabledAt,
+	)
+	return err
+}
+
+const recordFeedFetchSuccess = `-- name: RecordFeedFetchSuccess :exec
+UPDATE feeds
+SET last_error = '', consecutive_failures = 0, last_succeeded_at = $2, retry_after = NULL
+WHERE feeds.id = $1
+`
+
+type RecordFeedFetchSuccessParams struct {
+	ID              uuid.UUID
+	LastSucceededAt sql.NullTime
+}
+
+func (q *Queries) RecordFeedFetchSuccess(ctx context.Context, arg RecordFeedFetchSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFetchSuccess, arg.ID, arg.LastSucceededAt)
+	return err
+}
+
+const resetFeedFetchFailures = `-- name: ResetFeedFetchFailures :exec
+UPDATE feeds
+SET last_error = '', consecutive_failures = 0, retry_after = NULL, disabled_at = NULL
+WHERE feeds.id = $1
+`
+
+func (q *Queries) ResetFeedFetchFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetFeedFetchFailures, id)
+	return err
+}
+
+const updateFeedCacheValidators = `-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3
+WHERE feeds.id = $1
+`
+
+type UpdateFeedCacheValidatorsParams struct {
+	ID           uuid.UUID
+	Etag         string
+	LastModified string
+}
+
+func (q *Queries) UpdateFeedCacheValidators(ctx context.Context, arg UpdateFeedCacheValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
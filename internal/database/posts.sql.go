@@ -11,92 +11,101 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
+const adoptPost = `-- name: AdoptPost :execrows
+UPDATE posts
+SET guid = $1
+WHERE posts.id = (
+    SELECT legacy.id FROM posts AS legacy
+    WHERE legacy.feed_id = $2
+      AND legacy.guid = ANY($3::text[])
+      AND legacy.url = ANY($4::text[])
+    ORDER BY legacy.created_at, legacy.id
+    LIMIT 1
+)
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS current
+    WHERE current.feed_id = $2 AND current.guid = $1
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+type AdoptPostParams struct {
+	Guid          string
+	FeedID        uuid.UUID
+	PreviousGuids []string
+	Urls          []string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
+func (q *Queries) AdoptPost(ctx context.Context, arg AdoptPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptPost,
+		arg.Guid,
 		arg.FeedID,
+		pq.Array(arg.PreviousGuids),
+		pq.Array(arg.Urls),
 	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
-	)
-	return i, err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, title, posts.url, description, published_at, feed_id, feeds.id, feeds.created_at, feeds.updated_at, name, feeds.url, user_id, last_fetched_at FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_estimated, posts.guid, feeds.name AS feed_name, post_reads.read_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $1
 INNER JOIN feeds ON feeds.id = posts.feed_id
-WHERE feeds.user_id = $1
-ORDER BY published_at
-LIMIT $2
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = $1
+WHERE ($2::boolean IS NULL OR (post_reads.post_id IS NOT NULL) = $2)
+  AND ($3::uuid IS NULL OR posts.feed_id = $3)
+  AND ($4::timestamptz IS NULL OR posts.published_at >= $4)
+  AND ($5::timestamptz IS NULL OR posts.published_at < $5)
+  AND ($6::uuid IS NULL OR (posts.published_at, posts.id) < (
+    SELECT after_post.published_at, after_post.id FROM posts AS after_post WHERE after_post.id = $6
+  ))
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT $7
+OFFSET $8
 `
 
 type GetPostsForUserParams struct {
 	UserID uuid.UUID
+	Read   sql.NullBool
+	FeedID uuid.NullUUID
+	Since  sql.NullTime
+	Until  sql.NullTime
+	After  uuid.NullUUID
 	Limit  int32
+	Offset int32
 }
 
 type GetPostsForUserRow struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Title         string
-	Url           string
-	Description   string
-	PublishedAt   time.Time
-	FeedID        uuid.UUID
-	ID_2          uuid.UUID
-	CreatedAt_2   time.Time
-	UpdatedAt_2   time.Time
-	Name          string
-	Url_2         string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Title                string
+	Url                  string
+	Description          string
+	PublishedAt          time.Time
+	FeedID               uuid.UUID
+	PublishedAtEstimated bool
+	Guid                 string
+	FeedName             string
+	ReadAt               sql.NullTime
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.Read,
+		arg.FeedID,
+		arg.Since,
+		arg.Until,
+		arg.After,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -113,13 +122,10 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
-			&i.ID_2,
-			&i.CreatedAt_2,
-			&i.UpdatedAt_2,
-			&i.Name,
-			&i.Url_2,
-			&i.UserID,
-			&i.LastFetchedAt,
+			&i.PublishedAtEstimated,
+			&i.Guid,
+			&i.FeedName,
+			&i.ReadAt,
 		); err != nil {
 			return nil, err
 		}
@@ -133,3 +139,210 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getTimelineForUser = `-- name: GetTimelineForUser :many
+SELECT timeline.id, timeline.updated_at, timeline.title, timeline.url, timeline.description, timeline.published_at, timeline.feed_name, timeline.feed_url
+FROM (
+    SELECT DISTINCT ON (posts.url)
+        posts.id,
+        posts.updated_at,
+        posts.title,
+        posts.url,
+        posts.description,
+        posts.published_at,
+        feeds.name AS feed_name,
+        feeds.url AS feed_url
+    FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $1
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    ORDER BY posts.url, posts.published_at
+) AS timeline
+ORDER BY timeline.published_at DESC, timeline.id DESC
+LIMIT $2
+`
+
+type GetTimelineForUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type GetTimelineForUserRow struct {
+	ID          uuid.UUID
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedName    string
+	FeedUrl     string
+}
+
+func (q *Queries) GetTimelineForUser(ctx context.Context, arg GetTimelineForUserParams) ([]GetTimelineForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineForUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimelineForUserRow
+	for rows.Next() {
+		var i GetTimelineForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.FeedUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        posts.description,
+        query,
+        'StartSel=**, StopSel=**, MaxWords=25, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN to_tsquery('english', $1) AS query
+WHERE posts.search_vector @@ query
+  AND (
+    $2::boolean
+    OR EXISTS (
+        SELECT 1 FROM feed_follows
+        WHERE feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $3
+    )
+  )
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT $4
+`
+
+type SearchPostsParams struct {
+	Query    string
+	AllFeeds bool
+	UserID   uuid.UUID
+	Limit    int32
+}
+
+type SearchPostsRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts,
+		arg.Query,
+		arg.AllFeeds,
+		arg.UserID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, published_at_estimated, feed_id, guid)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+RETURNING id
+`
+
+type UpsertPostParams struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Title                string
+	Url                  string
+	Description          string
+	PublishedAt          time.Time
+	PublishedAtEstimated bool
+	FeedID               uuid.UUID
+	Guid                 string
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.PublishedAtEstimated,
+		arg.FeedID,
+		arg.Guid,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
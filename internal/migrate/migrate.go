@@ -0,0 +1,374 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoMigration is returned when rolling back a database on which no
+// migration is applied.
+var ErrNoMigration = errors.New("No migration to roll back")
+
+// lockKey identifies the advisory lock held while migrating, so that two
+// gator processes never migrate the same database at once.
+const lockKey = 7_366_127_001
+
+// Migration is one goose formatted file: the statements after the
+// "-- +goose Up" annotation migrate the schema, the ones after
+// "-- +goose Down" revert it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status tells whether a migration is applied, and since when.
+type Status struct {
+	Migration
+	AppliedAt sql.NullTime
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations found at the root of fsys. Their file names start
+// with their version, e.g. 001_users.sql.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migration, err := parse(name, string(content))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for idx := 1; idx < len(migrations); idx++ {
+		if migrations[idx].Version == migrations[idx-1].Version {
+			return nil, fmt.Errorf("Migrations %s and %s have the same version", migrations[idx-1].Name, migrations[idx].Name)
+		}
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func parse(name, content string) (Migration, error) {
+	prefix, _, _ := strings.Cut(path.Base(name), "_")
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return Migration{}, fmt.Errorf("Migration %s doesn't start with a version number", name)
+	}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if ok {
+			switch strings.ToLower(strings.TrimSpace(annotation)) {
+			case "up":
+				section = &up
+			case "down":
+				section = &down
+			}
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+		}
+	}
+	if strings.TrimSpace(up.String()) == "" {
+		return Migration{}, fmt.Errorf("Migration %s has no Up section", name)
+	}
+
+	return Migration{
+		Version: version,
+		Name:    name,
+		Up:      strings.TrimSpace(up.String()),
+		Down:    strings.TrimSpace(down.String()),
+	}, nil
+}
+
+// Status lists every known migration, oldest first. It only reads the
+// database: the schema_migrations table is left for Up, Down and Redo to
+// create, and the state of databases migrated with goose is read from goose's
+// table until then.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := currentVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{
+			Migration: migration,
+			AppliedAt: applied[migration.Version],
+		})
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that still have to be applied. Like Status,
+// it only reads the database.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.AppliedAt.Valid {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration, oldest first, and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if applied[migration.Version].Valid {
+				continue
+			}
+			err = apply(ctx, conn, migration)
+			if err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var migration Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		migration, err = m.revertLatest(ctx, conn)
+		return err
+	})
+	return migration, err
+}
+
+// Redo reverts the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	var migration Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		migration, err = m.revertLatest(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return apply(ctx, conn, migration)
+	})
+	return migration, err
+}
+
+func (m *Migrator) revertLatest(ctx context.Context, conn *sql.Conn) (Migration, error) {
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return Migration{}, err
+	}
+	for idx := len(m.migrations) - 1; idx >= 0; idx-- {
+		migration := m.migrations[idx]
+		if !applied[migration.Version].Valid {
+			continue
+		}
+		err = revert(ctx, conn, migration)
+		if err != nil {
+			return Migration{}, err
+		}
+		return migration, nil
+	}
+	return Migration{}, ErrNoMigration
+}
+
+// withLock runs f on a single connection holding the migration lock, once
+// the schema_migrations table exists.
+func (m *Migrator) withLock(ctx context.Context, f func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	if err != nil {
+		return err
+	}
+	// Unlock even when ctx is cancelled, the connection goes back to the pool.
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	err = ensureTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+// ensureTable creates the schema_migrations table. Databases that were
+// migrated with goose get the versions goose applied copied over.
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	var exists bool
+	err := conn.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`)
+	if err != nil {
+		return err
+	}
+
+	var fromGoose bool
+	err = tx.QueryRowContext(ctx, "SELECT to_regclass('goose_db_version') IS NOT NULL").Scan(&fromGoose)
+	if err != nil {
+		return err
+	}
+	if fromGoose {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at)\n"+gooseVersions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// gooseVersions selects the versions goose applied and when. goose appends a
+// row every time it applies or reverts a version, the latest one tells
+// whether the version is applied.
+const gooseVersions = `SELECT version_id, tstamp FROM (
+    SELECT DISTINCT ON (version_id) version_id, is_applied, tstamp
+    FROM goose_db_version
+    WHERE version_id > 0
+    ORDER BY version_id, id DESC
+) AS goose_versions
+WHERE is_applied`
+
+// querier is implemented by *sql.DB and *sql.Conn.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// currentVersions returns the applied versions without changing anything:
+// from schema_migrations, from goose_db_version when only goose migrated
+// the database, and none on a fresh database.
+func currentVersions(ctx context.Context, db querier) (map[int64]sql.NullTime, error) {
+	var exists, fromGoose bool
+	err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL, to_regclass('goose_db_version') IS NOT NULL").Scan(&exists, &fromGoose)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case exists:
+		return appliedVersions(ctx, db)
+	case fromGoose:
+		return scanVersions(db.QueryContext(ctx, gooseVersions))
+	}
+	return map[int64]sql.NullTime{}, nil
+}
+
+func appliedVersions(ctx context.Context, db querier) (map[int64]sql.NullTime, error) {
+	return scanVersions(db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations"))
+}
+
+func scanVersions(rows *sql.Rows, err error) (map[int64]sql.NullTime, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]sql.NullTime{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = sql.NullTime{Time: appliedAt, Valid: true}
+	}
+	return applied, rows.Err()
+}
+
+// apply runs the Up section of migration and records it in the same
+// transaction, so a failing migration leaves the schema untouched.
+func apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, migration.Up)
+	if err != nil {
+		return fmt.Errorf("Error applying migration %s: %w", migration.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)", migration.Version, time.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if migration.Down == "" {
+		return fmt.Errorf("Migration %s has no Down section and can't be reverted", migration.Name)
+	}
+	_, err = tx.ExecContext(ctx, migration.Down)
+	if err != nil {
+		return fmt.Errorf("Error reverting migration %s: %w", migration.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,150 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+	OwnerName   string `xml:"ownerName,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline is either a subscription, when XMLURL is set, or a category
+// holding other outlines.
+type Outline struct {
+	Text        string    `xml:"text,attr"`
+	Title       string    `xml:"title,attr,omitempty"`
+	Type        string    `xml:"type,attr,omitempty"`
+	XMLURL      string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL     string    `xml:"htmlUrl,attr,omitempty"`
+	Description string    `xml:"description,attr,omitempty"`
+	Outlines    []Outline `xml:"outline"`
+}
+
+// Subscription is a feed outline along with the path of the categories it is
+// nested in, joined with "/".
+type Subscription struct {
+	Title       string
+	XMLURL      string
+	HTMLURL     string
+	Description string
+	Folder      string
+}
+
+func Parse(r io.Reader) (*Document, error) {
+	var doc Document
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling OPML: %s", err)
+	}
+	return &doc, nil
+}
+
+// Subscriptions flattens the outline tree. Outlines that are neither a feed
+// nor a category, like a category without children, are returned with an
+// empty XMLURL so that they can be reported.
+func (d *Document) Subscriptions() []Subscription {
+	return collect(d.Body.Outlines, "", nil)
+}
+
+func collect(outlines []Outline, folder string, subscriptions []Subscription) []Subscription {
+	for _, outline := range outlines {
+		title := strings.TrimSpace(outline.Title)
+		if title == "" {
+			title = strings.TrimSpace(outline.Text)
+		}
+
+		if outline.XMLURL == "" && len(outline.Outlines) > 0 {
+			subfolder := title
+			if folder != "" {
+				subfolder = folder + "/" + title
+			}
+			subscriptions = collect(outline.Outlines, subfolder, subscriptions)
+			continue
+		}
+
+		subscriptions = append(subscriptions, Subscription{
+			Title:       title,
+			XMLURL:      strings.TrimSpace(outline.XMLURL),
+			HTMLURL:     strings.TrimSpace(outline.HTMLURL),
+			Description: strings.TrimSpace(outline.Description),
+			Folder:      folder,
+		})
+	}
+	return subscriptions
+}
+
+// New builds an OPML 2.0 document from subscriptions, nesting them in
+// category outlines following their folder path.
+func New(title string, subscriptions []Subscription) *Document {
+	doc := &Document{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, sub := range subscriptions {
+		outlines := &doc.Body.Outlines
+		if sub.Folder != "" {
+			for _, name := range strings.Split(sub.Folder, "/") {
+				outlines = &category(outlines, name).Outlines
+			}
+		}
+		*outlines = append(*outlines, Outline{
+			Text:        sub.Title,
+			Title:       sub.Title,
+			Type:        "rss",
+			XMLURL:      sub.XMLURL,
+			HTMLURL:     sub.HTMLURL,
+			Description: sub.Description,
+		})
+	}
+	return doc
+}
+
+// category returns the category outline called name among outlines, adding
+// it when missing.
+func category(outlines *[]Outline, name string) *Outline {
+	for idx := range *outlines {
+		outline := &(*outlines)[idx]
+		if outline.XMLURL == "" && outline.Text == name {
+			return outline
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1]
+}
+
+func (d *Document) Write(w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(d)
+	if err != nil {
+		return fmt.Errorf("Error marshalling OPML: %s", err)
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
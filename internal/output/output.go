@@ -0,0 +1,220 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Format is how a list of records is printed: an aligned table for people,
+// or JSON, CSV or YAML for scripts.
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	CSV   Format = "csv"
+	YAML  Format = "yaml"
+)
+
+// Set implements flag.Value so a Format can be bound to a --format flag.
+func (f *Format) Set(value string) error {
+	switch format := Format(strings.ToLower(value)); format {
+	case Table, JSON, CSV, YAML:
+		*f = format
+		return nil
+	}
+	return fmt.Errorf("Unknown format %s, expected table, json, csv or yaml", value)
+}
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+// List is a list of records sharing the same fields. Columns are the field
+// names, in snake_case, used as JSON and YAML keys and as CSV header. Values
+// are strings, numbers, booleans, times, uuids or nil.
+type List struct {
+	Columns []string
+	Rows    [][]any
+}
+
+func NewList(columns ...string) *List {
+	return &List{Columns: columns}
+}
+
+// Append adds a record, with one value per column.
+func (l *List) Append(values ...any) {
+	l.Rows = append(l.Rows, values)
+}
+
+func Write(w io.Writer, format Format, list *List) error {
+	switch format {
+	case JSON:
+		return writeJSON(w, list)
+	case CSV:
+		return writeCSV(w, list)
+	case YAML:
+		return writeYAML(w, list)
+	}
+	return writeTable(w, list)
+}
+
+func writeTable(w io.Writer, list *List) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	headers := make([]string, len(list.Columns))
+	for idx, column := range list.Columns {
+		headers[idx] = strings.ToUpper(strings.ReplaceAll(column, "_", " "))
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, row := range list.Rows {
+		cells := make([]string, len(row))
+		for idx, value := range row {
+			cells[idx] = tableCell(value)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func tableCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case time.Time:
+		return v.Local().Format("2006-01-02 15:04")
+	case float32, float64:
+		return fmt.Sprintf("%.3f", v)
+	case string:
+		// Tabs and new lines would break the alignment.
+		return strings.Join(strings.Fields(v), " ")
+	}
+	return fmt.Sprint(value)
+}
+
+func writeCSV(w io.Writer, list *List) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(list.Columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range list.Rows {
+		record := make([]string, len(row))
+		for idx, value := range row {
+			record[idx] = csvField(value)
+		}
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvField(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// writeJSON writes an array of objects. Keys keep the order of the columns
+// rather than the alphabetical order encoding/json uses for maps.
+func writeJSON(w io.Writer, list *List) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for rowIdx, row := range list.Rows {
+		if rowIdx > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("{")
+		for idx, value := range row {
+			if idx > 0 {
+				buf.WriteString(",")
+			}
+			err := writeJSONField(&buf, list.Columns[idx], value)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+
+	var out bytes.Buffer
+	err := json.Indent(&out, buf.Bytes(), "", "  ")
+	if err != nil {
+		return err
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(w)
+	return err
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value any) error {
+	encodedKey, err := marshal(key)
+	if err != nil {
+		return err
+	}
+	encodedValue, err := marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(encodedKey)
+	buf.WriteString(":")
+	buf.Write(encodedValue)
+	return nil
+}
+
+// writeYAML writes a sequence of mappings. Scalars are written as JSON,
+// which is valid YAML and keeps strings from being read as another type.
+func writeYAML(w io.Writer, list *List) error {
+	var buf bytes.Buffer
+	if len(list.Rows) == 0 {
+		buf.WriteString("[]\n")
+	}
+	for _, row := range list.Rows {
+		for idx, value := range row {
+			prefix := "  "
+			if idx == 0 {
+				prefix = "- "
+			}
+			encodedValue, err := marshal(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "%s%s: %s\n", prefix, list.Columns[idx], encodedValue)
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// marshal encodes value as JSON without escaping <, > and &, which only
+// matters when the output is embedded in HTML.
+func marshal(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,200 @@
+package publish
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// homepage is the channel link of RSS timelines that don't say where they
+// are published, RSS 2.0 requiring one.
+const homepage = "https://github.com/killuox/gator-blog-aggregator"
+
+// Timeline is a list of posts merged from several feeds, newest first.
+type Timeline struct {
+	// ID identifies the timeline across renders, as Atom requires.
+	ID          string
+	Title       string
+	Description string
+	// Link is the URL the timeline is published at, if any.
+	Link    string
+	Updated time.Time
+	Entries []Entry
+}
+
+type Entry struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Published   time.Time
+	Updated     time.Time
+	SourceTitle string
+	SourceURL   string
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomXMLNS string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	SelfLink      *atomLink `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate"`
+	GUID        rssGUID   `xml:"guid"`
+	Source      rssSource `xml:"source"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomPerson  `xml:"author"`
+	Summary   atomText    `xml:"summary"`
+	Source    *atomSource `xml:"source"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomSource struct {
+	ID    string   `xml:"id"`
+	Title string   `xml:"title"`
+	Link  atomLink `xml:"link"`
+}
+
+// WriteRSS writes timeline as an RSS 2.0 document.
+func WriteRSS(w io.Writer, timeline Timeline) error {
+	channel := rssChannel{
+		Title:         timeline.Title,
+		Link:          timeline.Link,
+		Description:   timeline.Description,
+		LastBuildDate: timeline.Updated.Format(time.RFC1123Z),
+		Generator:     "gator",
+	}
+	if channel.Link == "" {
+		channel.Link = homepage
+	} else {
+		channel.SelfLink = &atomLink{Href: timeline.Link, Rel: "self", Type: "application/rss+xml"}
+	}
+
+	for _, entry := range timeline.Entries {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			Description: entry.Description,
+			PubDate:     entry.Published.Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: false, Value: entry.ID},
+			Source:      rssSource{URL: entry.SourceURL, Title: entry.SourceTitle},
+		})
+	}
+
+	return write(w, rssDocument{
+		Version:   "2.0",
+		AtomXMLNS: "http://www.w3.org/2005/Atom",
+		Channel:   channel,
+	})
+}
+
+// WriteAtom writes timeline as an Atom 1.0 document. Entries are attributed
+// to the feed they come from.
+func WriteAtom(w io.Writer, timeline Timeline) error {
+	doc := atomDocument{
+		ID:      timeline.ID,
+		Title:   timeline.Title,
+		Updated: timeline.Updated.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "gator"},
+	}
+	if timeline.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Href: timeline.Link, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, entry := range timeline.Entries {
+		updated := entry.Updated
+		if updated.Before(entry.Published) {
+			updated = entry.Published
+		}
+		atom := atomEntry{
+			ID:        entry.ID,
+			Title:     entry.Title,
+			Link:      atomLink{Href: entry.Link, Rel: "alternate"},
+			Published: entry.Published.UTC().Format(time.RFC3339),
+			Updated:   updated.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: entry.SourceTitle},
+			Summary:   atomText{Type: "html", Value: entry.Description},
+		}
+		if entry.SourceURL != "" {
+			atom.Source = &atomSource{
+				ID:    entry.SourceURL,
+				Title: entry.SourceTitle,
+				Link:  atomLink{Href: entry.SourceURL, Rel: "self"},
+			}
+		}
+		doc.Entries = append(doc.Entries, atom)
+	}
+
+	return write(w, doc)
+}
+
+func write(w io.Writer, doc any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
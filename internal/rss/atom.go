@@ -0,0 +1,79 @@
+package rss
+
+type AtomFeed struct {
+	Title    AtomText    `xml:"title"`
+	Subtitle AtomText    `xml:"subtitle"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     AtomText   `xml:"title"`
+	Links     []AtomLink `xml:"link"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// AtomText holds an Atom text construct. The type attribute tells whether the
+// body is plain text, escaped html or inline xhtml markup.
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return t.InnerXML
+	}
+	return t.Text
+}
+
+// toRSS maps the Atom document onto the RSS model used by the rest of gator.
+func (f *AtomFeed) toRSS() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = f.Title.String()
+	feed.Channel.Link = alternateLink(f.Links)
+	feed.Channel.Description = f.Subtitle.String()
+
+	for _, entry := range f.Entries {
+		description := entry.Summary.String()
+		if description == "" {
+			description = entry.Content.String()
+		}
+
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     pubDate,
+			GUID:        entry.ID,
+		})
+	}
+	return &feed
+}
+
+// alternateLink returns the href of the rel="alternate" link. A link without a
+// rel attribute is an alternate link per RFC 4287.
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
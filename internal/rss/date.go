@@ -0,0 +1,150 @@
+package rss
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dateLayouts are tried in order once a date string has been cleaned up by
+// normalizeDate, so weekday names and zone abbreviations are already gone.
+var dateLayouts = []string{
+	// RFC 822 / RFC 1123 and their common variants
+	"_2 Jan 2006 15:04:05 -0700",
+	"_2 Jan 2006 15:04 -0700",
+	"_2 Jan 06 15:04:05 -0700",
+	"_2 Jan 06 15:04 -0700",
+	"_2 January 2006 15:04:05 -0700",
+	"_2 January 2006 15:04 -0700",
+	"_2 Jan 2006 15:04:05 -07:00",
+	"_2 Jan 2006 15:04:05",
+	"_2 Jan 2006",
+	"_2-Jan-06 15:04:05 -0700",
+	"_2-Jan-2006 15:04:05 -0700",
+
+	// ISO 8601 and Atom dates
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04-07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+
+	// ANSIC, UnixDate and free-form dates
+	"Jan _2 15:04:05 2006",
+	"Jan _2 15:04:05 -0700 2006",
+	"January _2, 2006 15:04:05",
+	"January _2, 2006",
+	"Jan _2, 2006",
+}
+
+// zoneOffsets maps the zone abbreviations seen in the wild to numeric offsets.
+// time.Parse only knows the offset of abbreviations of the local zone and
+// would silently treat the others as UTC.
+var zoneOffsets = map[string]string{
+	"Z":    "+0000",
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"SGT":  "+0800",
+	"HKT":  "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+	"HST":  "-1000",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"AST":  "-0400",
+	"ADT":  "-0300",
+}
+
+var weekdayPrefixes = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+var trailingComment = regexp.MustCompile(`\s*\([^)]*\)$`)
+
+// hourOffset matches a time ending with an offset of hours only, like the
+// "+01" of 2024-03-05T12:00:00+01, which ISO 8601 allows.
+var hourOffset = regexp.MustCompile(`(\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?)([+-]\d{2})$`)
+
+// ParseDate parses a publication date as found in RSS, Atom and JSON feeds.
+func ParseDate(value string) (time.Time, error) {
+	cleaned := normalizeDate(value)
+	if cleaned == "" {
+		return time.Time{}, fmt.Errorf("Empty publication date")
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, cleaned)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unrecognized publication date '%s'", value)
+}
+
+// PublishedAt returns the item publication date, or fetchedAt when the date is
+// missing or can't be parsed. The boolean reports whether the fallback was used.
+func (item RSSItem) PublishedAt(fetchedAt time.Time) (time.Time, bool) {
+	t, err := ParseDate(item.PubDate)
+	if err != nil {
+		return fetchedAt, true
+	}
+	return t, false
+}
+
+// normalizeDate strips the parts of a date that vary the most between
+// publishers: weekday names (which are often misspelled), trailing comments
+// like "(PST)" and zone abbreviations. Offsets of hours only get their
+// minutes added.
+func normalizeDate(value string) string {
+	value = trailingComment.ReplaceAllString(strings.TrimSpace(value), "")
+	fields := strings.Fields(value)
+
+	if len(fields) > 1 && isWeekday(fields[0]) {
+		fields = fields[1:]
+	}
+
+	for idx, field := range fields {
+		if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok {
+			fields[idx] = offset
+		}
+	}
+	return hourOffset.ReplaceAllString(strings.Join(fields, " "), "$1$2:00")
+}
+
+func isWeekday(field string) bool {
+	field = strings.ToLower(strings.TrimSuffix(field, ","))
+	if len(field) < 3 {
+		return false
+	}
+	for _, prefix := range weekdayPrefixes {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC 1123", "Tue, 05 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"RFC 1123 with numeric zone", "Tue, 05 Mar 2024 12:00:00 +0100", time.Date(2024, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"single digit day", "Tue, 5 Mar 2024 12:00:00 +0000", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"single digit day without weekday", "5 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"two digit year", "Tue, 05 Mar 24 12:00:00 +0000", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"EDT", "Tue, 05 Mar 2024 12:00:00 EDT", time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC)},
+		{"PST", "Tue, 05 Mar 2024 12:00:00 PST", time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC)},
+		{"Z", "Tue, 05 Mar 2024 12:00:00 Z", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"lowercase zone", "Tue, 05 Mar 2024 12:00:00 gmt", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"trailing zone comment", "Tue, 05 Mar 2024 12:00:00 +0000 (GMT)", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"misspelled weekday", "Tues, 05 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"wrong weekday", "Fri, 05 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"long weekday", "Tuesday, 05 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"long month", "05 March 2024 12:00:00 +0000", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"RFC 3339", "2024-03-05T12:00:00Z", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"RFC 3339 with fraction", "2024-03-05T12:00:00.123456Z", time.Date(2024, 3, 5, 12, 0, 0, 123456000, time.UTC)},
+		{"RFC 3339 with colon offset", "2024-03-05T12:00:00-07:00", time.Date(2024, 3, 5, 19, 0, 0, 0, time.UTC)},
+		{"ISO 8601 with offset without colon", "2024-03-05T12:00:00-0700", time.Date(2024, 3, 5, 19, 0, 0, 0, time.UTC)},
+		{"ISO 8601 with hour offset", "2024-03-05T12:00:00+01", time.Date(2024, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"ISO 8601 with fraction and hour offset", "2024-03-05T12:00:00.5-03", time.Date(2024, 3, 5, 15, 0, 0, 500000000, time.UTC)},
+		{"space separated with hour offset", "2024-03-05 12:00:00+01", time.Date(2024, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"date only", "2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"surrounding spaces", "  2024-03-05T12:00:00Z\n", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.value)
+			if err != nil {
+				t.Fatalf("ParseDate(%q) returned error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"yesterday",
+		"Tue, 05 Foo 2024 12:00:00 GMT",
+		"2024-13-05T12:00:00Z",
+		"(GMT)",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			got, err := ParseDate(value)
+			if err == nil {
+				t.Errorf("ParseDate(%q) = %v, want an error", value, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Tue, 05 Mar 2024 12:00:00 GMT", "05 Mar 2024 12:00:00 +0000"},
+		{"Wednesday, 06 Mar 2024 12:00:00 EDT", "06 Mar 2024 12:00:00 -0400"},
+		{"Tue, 05 Mar 2024 12:00:00 +0000 (GMT)", "05 Mar 2024 12:00:00 +0000"},
+		{"  5   Mar  2024 ", "5 Mar 2024"},
+		{"2024-03-05T12:00:00+01", "2024-03-05T12:00:00+01:00"},
+		{"2024-03-05T12:00:00-07:00", "2024-03-05T12:00:00-07:00"},
+		{"2024-03-05", "2024-03-05"},
+		{"Sunny", "Sunny"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got := normalizeDate(tt.value)
+			if got != tt.want {
+				t.Errorf("normalizeDate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishedAt(t *testing.T) {
+	fetchedAt := time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		pubDate       string
+		want          time.Time
+		wantEstimated bool
+	}{
+		{"valid date", "Tue, 05 Mar 2024 12:00:00 GMT", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC), false},
+		{"missing date", "", fetchedAt, true},
+		{"unparseable date", "last Tuesday", fetchedAt, true},
+		{"unknown month", "05 Foo 2024 12:00:00 GMT", fetchedAt, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, estimated := RSSItem{PubDate: tt.pubDate}.PublishedAt(fetchedAt)
+			if !got.Equal(tt.want) || estimated != tt.wantEstimated {
+				t.Errorf("PublishedAt(%q) = %v, %t, want %v, %t", tt.pubDate, got, estimated, tt.want, tt.wantEstimated)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package rss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrHTMLPage is returned when the fetched document is a web page rather than
+// a feed. DiscoverFeeds can find the feeds the page links to.
+var ErrHTMLPage = errors.New("Document is an HTML page, not a feed")
+
+// commonFeedPaths are probed when a page doesn't advertise its feeds.
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss"}
+
+var feedMediaTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+var (
+	linkTag       = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	tagAttribute  = regexp.MustCompile(`(?s)([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlSignature = regexp.MustCompile(`(?i)^(<!--.*?-->\s*)*<(!doctype\s+html|html|head|body)\b`)
+)
+
+type DiscoveredFeed struct {
+	URL   string
+	Title string
+	Type  string
+}
+
+// DiscoverFeeds returns the feeds of the web page at pageURL: the ones it
+// advertises with <link rel="alternate"> tags or, when there are none, the
+// ones found at commonFeedPaths of the same site.
+func (f *Fetcher) DiscoverFeeds(ctx context.Context, pageURL string) ([]DiscoveredFeed, error) {
+	resp, body, err := f.get(ctx, pageURL, "text/html, application/xhtml+xml;q=0.9, */*;q=0.5", CacheValidators{})
+	if err != nil {
+		return nil, err
+	}
+
+	base := resp.Request.URL
+	feeds := alternateFeeds(base, body)
+	if len(feeds) > 0 {
+		return feeds, nil
+	}
+
+	for _, path := range commonFeedPaths {
+		candidate := base.ResolveReference(&url.URL{Path: path}).String()
+		feed, err := f.FetchFeed(ctx, candidate)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		feeds = append(feeds, DiscoveredFeed{
+			URL:   candidate,
+			Title: feed.Channel.Title,
+		})
+	}
+	return feeds, nil
+}
+
+// alternateFeeds parses the <link rel="alternate"> tags pointing to feeds.
+// Relative hrefs are resolved against base.
+func alternateFeeds(base *url.URL, page []byte) []DiscoveredFeed {
+	var feeds []DiscoveredFeed
+	seen := map[string]bool{}
+
+	for _, tag := range linkTag.FindAll(page, -1) {
+		attrs := map[string]string{}
+		for _, match := range tagAttribute.FindAllSubmatch(tag, -1) {
+			value := string(match[2]) + string(match[3]) + string(match[4])
+			attrs[strings.ToLower(string(match[1]))] = html.UnescapeString(value)
+		}
+
+		mediaType := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if !hasToken(attrs["rel"], "alternate") || !feedMediaTypes[mediaType] || attrs["href"] == "" {
+			continue
+		}
+
+		href, err := base.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil || seen[href.String()] {
+			continue
+		}
+		seen[href.String()] = true
+
+		feeds = append(feeds, DiscoveredFeed{
+			URL:   href.String(),
+			Title: strings.TrimSpace(attrs["title"]),
+			Type:  mediaType,
+		})
+	}
+	return feeds
+}
+
+func hasToken(list string, token string) bool {
+	for _, field := range strings.Fields(strings.ToLower(list)) {
+		if field == token {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTML(body []byte) bool {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	return htmlSignature.Match(trimmed)
+}
@@ -0,0 +1,197 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxBodySize  = 10 << 20
+	DefaultMaxRedirects = 5
+
+	acceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, application/json;q=0.8, */*;q=0.5"
+)
+
+var (
+	// ErrNotModified is returned when the server answered 304 Not Modified.
+	ErrNotModified = errors.New("Feed not modified")
+
+	ErrBodyTooLarge     = errors.New("Feed body is too large")
+	ErrTooManyRedirects = errors.New("Too many redirects")
+
+	// ErrClientStatus and ErrServerStatus classify a StatusError, so callers
+	// can use errors.Is without looking at the status code.
+	ErrClientStatus = errors.New("Client error status")
+	ErrServerStatus = errors.New("Server error status")
+)
+
+// StatusError is returned when the server answers with a status code that
+// isn't a success, a redirect or 304 Not Modified.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status fetching %s: %s", e.URL, e.Status)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.StatusCode >= 500 {
+		return ErrServerStatus
+	}
+	return ErrClientStatus
+}
+
+// CacheValidators are the response headers used to make the next request for
+// the same feed conditional.
+type CacheValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// Fetcher downloads feeds. Zero fields fall back to the package defaults.
+type Fetcher struct {
+	Timeout      time.Duration
+	MaxBodySize  int64
+	MaxRedirects int
+	UserAgent    string
+}
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Timeout:      DefaultTimeout,
+		MaxBodySize:  DefaultMaxBodySize,
+		MaxRedirects: DefaultMaxRedirects,
+		UserAgent:    "gator",
+	}
+}
+
+var defaultFetcher = NewFetcher()
+
+func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	return defaultFetcher.FetchFeed(ctx, feedURL)
+}
+
+func FetchFeedConditional(ctx context.Context, feedURL string, validators CacheValidators) (*RSSFeed, CacheValidators, error) {
+	return defaultFetcher.FetchFeedConditional(ctx, feedURL, validators)
+}
+
+func (f *Fetcher) FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	feed, _, err := f.FetchFeedConditional(ctx, feedURL, CacheValidators{})
+	return feed, err
+}
+
+// FetchFeedConditional fetches the feed with If-None-Match and
+// If-Modified-Since set from validators, and returns the validators to use for
+// the next fetch.
+func (f *Fetcher) FetchFeedConditional(ctx context.Context, feedURL string, validators CacheValidators) (*RSSFeed, CacheValidators, error) {
+	resp, body, err := f.get(ctx, feedURL, acceptHeader, validators)
+	if err != nil {
+		return &RSSFeed{}, validators, err
+	}
+
+	response, err := parseFeed(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return &RSSFeed{}, validators, err
+	}
+
+	sanitizeHtml(response)
+	return response, CacheValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// get sends a GET request for targetURL and returns the response along with
+// its body, which has already been read and closed.
+func (f *Fetcher) get(ctx context.Context, targetURL string, accept string, validators CacheValidators) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting feed url: %w", err)
+	}
+
+	req.Header.Set("User-Agent", f.userAgent())
+	req.Header.Set("Accept", accept)
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error fetching feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &StatusError{
+			URL:        targetURL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	body, err := f.readBody(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+func (f *Fetcher) client() *http.Client {
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	maxRedirects := f.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return ErrTooManyRedirects
+			}
+			return nil
+		},
+	}
+}
+
+// readBody reads at most MaxBodySize bytes and fails instead of truncating
+// larger documents.
+func (f *Fetcher) readBody(body io.Reader) ([]byte, error) {
+	maxBodySize := f.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading body: %w", err)
+	}
+	if int64(len(data)) > maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+func (f *Fetcher) userAgent() string {
+	if f.UserAgent == "" {
+		return "gator"
+	}
+	return f.UserAgent
+}
@@ -0,0 +1,86 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            JSONFeedID `json:"id"`
+	URL           string     `json:"url"`
+	ExternalURL   string     `json:"external_url"`
+	Title         string     `json:"title"`
+	Summary       string     `json:"summary"`
+	ContentHTML   string     `json:"content_html"`
+	ContentText   string     `json:"content_text"`
+	DatePublished string     `json:"date_published"`
+	DateModified  string     `json:"date_modified"`
+}
+
+// JSONFeedID is the id of an item. JSON Feed 1.1 requires readers to coerce
+// ids given as numbers or other types to strings.
+type JSONFeedID string
+
+func (id *JSONFeedID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return err
+		}
+		*id = JSONFeedID(value)
+		return nil
+	}
+	*id = JSONFeedID(data)
+	return nil
+}
+
+// toRSS maps the JSON Feed document onto the RSS model used by the rest of gator.
+func (f *JSONFeed) toRSS() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = f.Title
+	feed.Channel.Link = f.HomePageURL
+	feed.Channel.Description = f.Description
+
+	for _, item := range f.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+
+		description := item.Summary
+		if description == "" {
+			description = item.ContentHTML
+		}
+		if description == "" {
+			description = item.ContentText
+		}
+
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     pubDate,
+			GUID:        string(item.ID),
+		})
+	}
+	return &feed
+}
@@ -0,0 +1,41 @@
+package rss
+
+import "testing"
+
+func TestParseJSONItemIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"string", `"https://example.com/posts/1"`, "https://example.com/posts/1"},
+		{"tag", `"tag:example.com,2024:été"`, "tag:example.com,2024:été"},
+		{"escaped string", `"post \"1\""`, `post "1"`},
+		{"integer", `123`, "123"},
+		{"large integer", `12345678901234567890`, "12345678901234567890"},
+		{"float", `1.5`, "1.5"},
+		{"boolean", `true`, "true"},
+		{"null", `null`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{
+				"version": "https://jsonfeed.org/version/1.1",
+				"title": "Example",
+				"items": [{"id": ` + tt.id + `, "url": "https://example.com/posts/1", "title": "First"}]
+			}`)
+			feed, err := parseJSON(body)
+			if err != nil {
+				t.Fatalf("parseJSON returned error: %v", err)
+			}
+			if len(feed.Channel.Item) != 1 {
+				t.Fatalf("parseJSON returned %d items, want 1", len(feed.Channel.Item))
+			}
+			got := feed.Channel.Item[0].GUID
+			if got != tt.want {
+				t.Errorf("GUID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package rss
+
+// RDFFeed is an RSS 1.0 (or 0.90) document. Unlike RSS 2.0, its items are
+// siblings of the channel rather than children of it.
+type RDFFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	// Date comes from the Dublin Core module, RSS 1.0 has no pubDate.
+	Date string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+// toRSS maps the RDF document onto the RSS model used by the rest of gator.
+func (f *RDFFeed) toRSS() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = f.Channel.Title
+	feed.Channel.Link = f.Channel.Link
+	feed.Channel.Description = f.Channel.Description
+
+	for _, item := range f.Items {
+		link := item.Link
+		if link == "" {
+			link = item.About
+		}
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: item.Description,
+			PubDate:     item.Date,
+		})
+	}
+	return &feed
+}
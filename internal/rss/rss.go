@@ -1,56 +1,232 @@
 package rss
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
-	"io"
-	"net/http"
+	"mime"
+	"strings"
+
+	"github.com/killuox/gator-blog-aggregator/internal/urlnorm"
 )
 
+// ErrNotFeed is returned when a document is neither RSS, Atom nor JSON Feed.
+var ErrNotFeed = errors.New("Document is not a feed")
+
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
+		Title string `xml:"title"`
+		// Link is picked among Links once the document is parsed.
+		Link        string    `xml:"-"`
+		Links       []XMLLink `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
+// XMLLink is a link element of an RSS channel. The channel link shares its
+// name with the <atom:link rel="self"/> many feeds add, which is empty.
+type XMLLink struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// channelLink returns the text of the first link without a namespace.
+func channelLink(links []XMLLink) string {
+	for _, link := range links {
+		text := strings.TrimSpace(link.Text)
+		if link.XMLName.Space == "" && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
 type RSSItem struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+}
+
+// Identity returns the key used to recognize the item across fetches: its
+// GUID, or the canonical form of its link when the feed doesn't provide one.
+// GUIDs that are URLs are compared in canonical form too, so that a
+// permalink GUID matches the posts keyed on the link before.
+func (item RSSItem) Identity() string {
+	guid := strings.TrimSpace(item.GUID)
+	if guid != "" && !isURL(guid) {
+		return guid
+	}
+	if guid != "" {
+		key, err := urlnorm.Key(guid)
+		if err != nil {
+			return guid
+		}
+		return key
+	}
+	key, err := urlnorm.Key(item.Link)
+	if err != nil {
+		return strings.TrimSpace(item.Link)
+	}
+	return key
+}
+
+// LinkForms returns the ways the item link has been stored over time: as
+// given, normalized by the 010_canonical_urls migration, and cleaned.
+func (item RSSItem) LinkForms() []string {
+	link := strings.TrimSpace(item.Link)
+	if link == "" {
+		return nil
+	}
+	forms := []string{link}
+	normalized, err := urlnorm.Normalize(link)
+	if err == nil {
+		forms = appendNew(forms, normalized)
+	}
+	return appendNew(forms, item.CleanLink())
+}
+
+// PreviousIdentities returns the keys the item may be stored with by older
+// versions of gator, leaving the current Identity out: the 009_posts_guid
+// migration keyed existing posts on their link, which 010_canonical_urls
+// then replaced with its canonical form, and GUIDs that are URLs were kept
+// as given.
+func (item RSSItem) PreviousIdentities() []string {
+	var identities []string
+	candidates := item.LinkForms()
+	guid := strings.TrimSpace(item.GUID)
+	if guid != "" {
+		candidates = append(candidates, guid)
+	}
+	key, err := urlnorm.Key(item.Link)
+	if err == nil {
+		candidates = append(candidates, key)
+	}
+	identity := item.Identity()
+	for _, candidate := range candidates {
+		if candidate != identity {
+			identities = appendNew(identities, candidate)
+		}
+	}
+	return identities
+}
+
+func isURL(value string) bool {
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	client := &http.Client{}
-	req, err := http.NewRequestWithContext(context.Background(), "GET", feedURL, nil)
+func appendNew(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// CleanLink returns the item link without tracking parameters and fragment.
+// Links that can't be parsed are returned as is.
+func (item RSSItem) CleanLink() string {
+	link, err := urlnorm.Clean(item.Link)
 	if err != nil {
-		return &RSSFeed{}, fmt.Errorf("Error getting feed url: %s", err)
+		return strings.TrimSpace(item.Link)
+	}
+	return link
+}
+
+// parseFeed picks the parser from the Content-Type header and falls back to
+// sniffing the document when the header is missing or too generic.
+func parseFeed(contentType string, body []byte) (*RSSFeed, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/feed+json", "application/json":
+		return parseJSON(body)
+	case "application/rss+xml", "application/atom+xml", "application/rdf+xml":
+		return parseXML(body)
+	case "text/html", "application/xhtml+xml":
+		return &RSSFeed{}, ErrHTMLPage
 	}
 
-	req.Header.Set("User-Agent", "gator")
+	if isHTML(body) {
+		return &RSSFeed{}, ErrHTMLPage
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseJSON(body)
+	}
+	return parseXML(body)
+}
+
+func parseJSON(body []byte) (*RSSFeed, error) {
+	var feed JSONFeed
+	err := json.Unmarshal(body, &feed)
+	if err != nil {
+		return &RSSFeed{}, fmt.Errorf("Error unmarshalling JSON Feed: %s", err)
+	}
+	if !strings.HasPrefix(feed.Version, "https://jsonfeed.org/version/") {
+		return &RSSFeed{}, ErrNotFeed
+	}
+	return feed.toRSS(), nil
+}
 
-	resp, err := client.Do(req)
+// parseXML looks at the root element to tell RSS 2.0, RSS 1.0 (RDF) and
+// Atom documents apart.
+func parseXML(body []byte) (*RSSFeed, error) {
+	root, err := rootElement(body)
 	if err != nil {
-		return &RSSFeed{}, fmt.Errorf("Error reading body: %s", err)
+		return &RSSFeed{}, fmt.Errorf("Error unmarshalling XML: %s", err)
+	}
+
+	if root != "rss" && root != "feed" && root != "RDF" {
+		return &RSSFeed{}, ErrNotFeed
+	}
+
+	if root == "feed" {
+		var atom AtomFeed
+		err = xml.Unmarshal(body, &atom)
+		if err != nil {
+			return &RSSFeed{}, fmt.Errorf("Error unmarshalling Atom: %s", err)
+		}
+		return atom.toRSS(), nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	if root == "RDF" {
+		var rdf RDFFeed
+		err = xml.Unmarshal(body, &rdf)
+		if err != nil {
+			return &RSSFeed{}, fmt.Errorf("Error unmarshalling RDF: %s", err)
+		}
+		return rdf.toRSS(), nil
+	}
 
 	var response RSSFeed
 	err = xml.Unmarshal(body, &response)
 	if err != nil {
 		return &RSSFeed{}, fmt.Errorf("Error unmarshalling XML: %s", err)
 	}
-
-	sanitizeHtml(&response)
+	response.Channel.Link = channelLink(response.Channel.Links)
 	return &response, nil
 }
 
+func rootElement(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
 func sanitizeHtml(feed *RSSFeed) {
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
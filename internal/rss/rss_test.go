@@ -0,0 +1,128 @@
+package rss
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		name string
+		item RSSItem
+		want string
+	}{
+		{"guid", RSSItem{GUID: " post-123 ", Link: "https://example.com/post/"}, "post-123"},
+		{"url guid", RSSItem{GUID: "https://example.com/?p=123", Link: "https://example.com/post/"}, "example.com?p=123"},
+		{"permalink guid", RSSItem{GUID: "https://example.com/post/", Link: "http://example.com/post"}, "example.com/post"},
+		{"tag guid", RSSItem{GUID: "tag:example.com,2024:post-1", Link: "https://example.com/post/"}, "tag:example.com,2024:post-1"},
+		{"no guid", RSSItem{Link: "https://Example.com/post/?utm_source=rss#top"}, "example.com/post"},
+		{"neither guid nor link", RSSItem{Title: "Untitled"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.item.Identity()
+			if got != tt.want {
+				t.Errorf("Identity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Posts stored before the GUID of items was used are keyed on their link,
+// the next fetch must find them under one of the previous identities rather
+// than store them again.
+func TestPreviousIdentities(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     RSSItem
+		want     []string
+		wantUrls []string
+	}{
+		{
+			name:     "WordPress guid",
+			item:     RSSItem{GUID: "https://example.com/?p=123", Link: "https://Example.com/post/"},
+			want:     []string{"https://Example.com/post/", "https://example.com/post", "https://example.com/?p=123", "example.com/post"},
+			wantUrls: []string{"https://Example.com/post/", "https://example.com/post"},
+		},
+		{
+			name:     "permalink guid",
+			item:     RSSItem{GUID: "https://example.com/post/", Link: "https://example.com/post/"},
+			want:     []string{"https://example.com/post/", "https://example.com/post"},
+			wantUrls: []string{"https://example.com/post/", "https://example.com/post"},
+		},
+		{
+			name:     "urn guid with tracking link",
+			item:     RSSItem{GUID: "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a", Link: "https://example.com/post?utm_source=rss"},
+			want:     []string{"https://example.com/post?utm_source=rss", "https://example.com/post", "example.com/post"},
+			wantUrls: []string{"https://example.com/post?utm_source=rss", "https://example.com/post"},
+		},
+		{
+			name:     "no guid",
+			item:     RSSItem{Link: "https://example.com/post"},
+			want:     []string{"https://example.com/post"},
+			wantUrls: []string{"https://example.com/post"},
+		},
+		{
+			name: "no link",
+			item: RSSItem{GUID: "tag:example.com,2024:post-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.item.PreviousIdentities()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PreviousIdentities() = %q, want %q", got, tt.want)
+			}
+			if slices.Contains(got, tt.item.Identity()) {
+				t.Errorf("PreviousIdentities() = %q contains the current identity", got)
+			}
+			urls := tt.item.LinkForms()
+			if !slices.Equal(urls, tt.wantUrls) {
+				t.Errorf("LinkForms() = %q, want %q", urls, tt.wantUrls)
+			}
+		})
+	}
+}
+
+func TestParseXMLChannelLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		want    string
+	}{
+		{"link only", `<link>https://example.com/</link>`, "https://example.com/"},
+		{"atom self link after", `<link>https://example.com/</link><atom:link href="https://example.com/index.xml" rel="self" type="application/rss+xml"/>`, "https://example.com/"},
+		{"atom self link before", `<atom:link href="https://example.com/index.xml" rel="self" type="application/rss+xml"/><link>https://example.com/</link>`, "https://example.com/"},
+		{"surrounding spaces", `<link>
+			https://example.com/
+		</link>`, "https://example.com/"},
+		{"atom self link only", `<atom:link href="https://example.com/index.xml" rel="self"/>`, ""},
+		{"no link", ``, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Example</title>
+    ` + tt.channel + `
+    <description>Posts</description>
+    <item><title>First</title><link>https://example.com/posts/first/</link></item>
+  </channel>
+</rss>`)
+			feed, err := parseXML(body)
+			if err != nil {
+				t.Fatalf("parseXML returned error: %v", err)
+			}
+			if feed.Channel.Link != tt.want {
+				t.Errorf("Channel.Link = %q, want %q", feed.Channel.Link, tt.want)
+			}
+			if len(feed.Channel.Item) != 1 || feed.Channel.Item[0].Link != "https://example.com/posts/first/" {
+				t.Errorf("Channel.Item = %+v, want the first post", feed.Channel.Item)
+			}
+		})
+	}
+}
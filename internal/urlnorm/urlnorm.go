@@ -0,0 +1,136 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that only exist to attribute traffic
+// and never change the document they point to.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"igshid":  true,
+	"yclid":   true,
+}
+
+// Clean removes the fragment and tracking parameters of rawURL and keeps the
+// rest as given, it is the form URLs are stored and fetched in. URLs without
+// a scheme are assumed to be https.
+func Clean(rawURL string) (string, error) {
+	u, err := parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.RawQuery != "" {
+		u.RawQuery = strings.Join(stripTracking(u.RawQuery), "&")
+		u.ForceQuery = false
+	}
+	return u.String(), nil
+}
+
+// Normalize cleans rawURL up so that equivalent URLs compare equal: the
+// scheme and host are lowercased, default ports, fragments, tracking
+// parameters and trailing slashes are removed, and the remaining query
+// parameters are sorted. URLs without a scheme are assumed to be https.
+// Normalized URLs are only used to compare URLs, see Key.
+//
+// The 010_canonical_urls migration mirrors this in SQL, keep both in sync.
+func Normalize(rawURL string) (string, error) {
+	u, err := parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	// The host and path are taken as given rather than from u, whose String
+	// method would escape them again, like the SQL version does.
+	_, rest, _ := strings.Cut(withScheme(rawURL), "://")
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	host, path := rest, ""
+	if idx := strings.Index(rest, "/"); idx >= 0 {
+		host, path = rest[:idx], rest[idx:]
+	}
+
+	host = strings.ToLower(host)
+	if (u.Scheme == "http" && strings.HasSuffix(host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	path = strings.TrimRight(path, "/")
+
+	params := stripTracking(u.RawQuery)
+	if len(params) == 0 {
+		return u.Scheme + "://" + host + path, nil
+	}
+	sort.Strings(params)
+	return u.Scheme + "://" + host + path + "?" + strings.Join(params, "&"), nil
+}
+
+// withScheme trims rawURL and prepends https:// when it has no scheme.
+func withScheme(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	return rawURL
+}
+
+func parse(rawURL string) (*url.URL, error) {
+	if strings.TrimSpace(rawURL) == "" {
+		return nil, fmt.Errorf("Empty url")
+	}
+	rawURL = withScheme(rawURL)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid url '%s': %s", rawURL, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported url scheme '%s'", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Url '%s' has no host", rawURL)
+	}
+	return u, nil
+}
+
+// Key returns the form used to compare URLs: the normalized URL without its
+// scheme, so that http and https versions of a URL match.
+func Key(rawURL string) (string, error) {
+	normalized, err := Normalize(rawURL)
+	if err != nil {
+		return "", err
+	}
+	_, key, _ := strings.Cut(normalized, "://")
+	return key, nil
+}
+
+// stripTracking returns the parameters of rawQuery but the tracking ones. It
+// works on the raw pairs so that their encoding is preserved.
+func stripTracking(rawQuery string) []string {
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(param, "=")
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "utm_") || trackingParams[name] {
+			continue
+		}
+		params = append(params, param)
+	}
+	return params
+}
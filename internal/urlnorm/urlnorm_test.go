@@ -0,0 +1,116 @@
+package urlnorm
+
+import "testing"
+
+// The cases of TestNormalize hold for the gator_normalize_url function of the
+// 010_canonical_urls migration too.
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"unchanged", "https://example.com/feed", "https://example.com/feed"},
+		{"scheme and host case", "HTTPS://Example.COM/Feed", "https://example.com/Feed"},
+		{"default http port", "http://example.com:80/feed", "http://example.com/feed"},
+		{"default https port", "https://example.com:443/feed", "https://example.com/feed"},
+		{"other port", "https://example.com:8443/feed", "https://example.com:8443/feed"},
+		{"http port on https", "https://example.com:80/feed", "https://example.com:80/feed"},
+		{"trailing slash", "https://example.com/feed/", "https://example.com/feed"},
+		{"trailing slashes", "https://example.com/feed//", "https://example.com/feed"},
+		{"root", "https://example.com/", "https://example.com"},
+		{"utm parameters", "https://example.com/feed?utm_source=rss&utm_medium=feed", "https://example.com/feed"},
+		{"tracking parameters", "https://example.com/feed?fbclid=abc&id=1&GCLID=def", "https://example.com/feed?id=1"},
+		{"parameter order", "https://example.com/feed?b=2&a=1", "https://example.com/feed?a=1&b=2"},
+		{"empty parameters", "https://example.com/feed?&a=1&", "https://example.com/feed?a=1"},
+		{"empty query", "https://example.com/feed?", "https://example.com/feed"},
+		{"fragment", "https://example.com/feed#latest", "https://example.com/feed"},
+		{"fragment after query", "https://example.com/feed?a=1#latest", "https://example.com/feed?a=1"},
+		{"no scheme", "example.com/feed", "https://example.com/feed"},
+		{"surrounding spaces", "  https://example.com/feed \n", "https://example.com/feed"},
+		{"escaped path", "https://example.com/caf%C3%A9/a%20b", "https://example.com/caf%C3%A9/a%20b"},
+		{"unescaped path", "https://example.com/café", "https://example.com/café"},
+		{"escaped query", "https://example.com/feed?q=a%20b&tag=go+lang", "https://example.com/feed?q=a%20b&tag=go+lang"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.value)
+			if err != nil {
+				t.Fatalf("Normalize(%q) returned error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"ftp://example.com/feed",
+		"https://",
+		"https:///feed",
+		"https://exa mple.com/feed",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			got, err := Normalize(value)
+			if err == nil {
+				t.Errorf("Normalize(%q) = %q, want an error", value, got)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"https://example.com/feed", "example.com/feed"},
+		{"http://example.com/feed/", "example.com/feed"},
+		{"HTTP://EXAMPLE.com:80/feed?utm_source=x#top", "example.com/feed"},
+		{"example.com/feed?b=2&a=1", "example.com/feed?a=1&b=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Key(tt.value)
+			if err != nil {
+				t.Fatalf("Key(%q) returned error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Key(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"https://Example.com:443/Feed/", "https://Example.com:443/Feed/"},
+		{"https://example.com/feed?b=2&utm_source=x&a=1", "https://example.com/feed?b=2&a=1"},
+		{"https://example.com/feed?fbclid=abc", "https://example.com/feed"},
+		{"https://example.com/post#comments", "https://example.com/post"},
+		{"example.com/feed", "https://example.com/feed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Clean(tt.value)
+			if err != nil {
+				t.Fatalf("Clean(%q) returned error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
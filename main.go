@@ -3,22 +3,45 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"math"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/killuox/gator-blog-aggregator/internal/config"
 	"github.com/killuox/gator-blog-aggregator/internal/database"
+	"github.com/killuox/gator-blog-aggregator/internal/migrate"
+	"github.com/killuox/gator-blog-aggregator/internal/output"
 	"github.com/killuox/gator-blog-aggregator/internal/rss"
-	_ "github.com/lib/pq"
+	"github.com/killuox/gator-blog-aggregator/internal/urlnorm"
+	"github.com/lib/pq"
+)
+
+const (
+	// maxFeedFailures is the number of consecutive failed fetches after which
+	// a feed is disabled.
+	maxFeedFailures = 10
+	feedBackoffBase = time.Minute
+	feedBackoffMax  = 24 * time.Hour
 )
 
 type state struct {
-	config *config.Config
-	db     *database.Queries
+	// ctx is cancelled on SIGINT or SIGTERM.
+	ctx      context.Context
+	config   *config.Config
+	db       *database.Queries
+	fetcher  *rss.Fetcher
+	migrator *migrate.Migrator
 }
 
 type command struct {
@@ -48,9 +71,21 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	migrator, err := newMigrator(db)
+	if err != nil {
+		fmt.Printf("Error loading the migrations: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	state := &state{
-		config: &cfg,
-		db:     dbQueries,
+		ctx:      ctx,
+		config:   &cfg,
+		db:       dbQueries,
+		fetcher:  rss.NewFetcher(),
+		migrator: migrator,
 	}
 
 	commands := commands{
@@ -58,7 +93,10 @@ func main() {
 	}
 
 	// commands
+	commands.register("migrate", handlerMigrate)
 	commands.register("login", handlerLogin)
+	commands.register("logout", handlerLogout)
+	commands.register("token", middlewareLoggedIn(handlerToken))
 	commands.register("register", handlerRegister)
 	commands.register("reset", handlerReset)
 	commands.register("users", handlerUsers)
@@ -69,6 +107,18 @@ func main() {
 	commands.register("following", middlewareLoggedIn(handlerFollowing))
 	commands.register("unfollow", middlewareLoggedIn(handlerUnFollow))
 	commands.register("browse", middlewareLoggedIn(handlerBrowse))
+	commands.register("read", middlewareLoggedIn(handlerRead))
+	commands.register("unread", middlewareLoggedIn(handlerUnread))
+	commands.register("markallread", middlewareLoggedIn(handlerMarkAllRead))
+	commands.register("star", middlewareLoggedIn(handlerStar))
+	commands.register("unstar", middlewareLoggedIn(handlerUnstar))
+	commands.register("starred", middlewareLoggedIn(handlerStarred))
+	commands.register("search", middlewareLoggedIn(handlerSearch))
+	commands.register("feedstatus", handlerFeedStatus)
+	commands.register("import-opml", middlewareLoggedIn(handlerImportOPML))
+	commands.register("export-opml", middlewareLoggedIn(handlerExportOPML))
+	commands.register("publish", middlewareLoggedIn(handlerPublish))
+	commands.register("serve", handlerServe)
 
 	if len(os.Args) < 2 {
 		fmt.Print("Not enough arguments provided.\n")
@@ -90,7 +140,13 @@ func main() {
 		handler: handler,
 	}
 
-	err = commands.run(state, cmd)
+	if cName != "migrate" {
+		err = checkSchema(state)
+	}
+	if err == nil {
+		err = commands.run(state, cmd)
+	}
+	stop()
 	if err != nil {
 		fmt.Printf("Error while running the command: %s\n", err)
 		os.Exit(1)
@@ -105,17 +161,30 @@ func handlerLogin(s *state, cmd command) error {
 	}
 	name := cmd.args[0]
 
-	user, err := s.db.GetUserByName(context.Background(), name)
+	user, err := s.db.GetUserByName(s.ctx, name)
 	if err != nil {
 		fmt.Printf("You can't login to an account that doesn't exist!\n")
 		os.Exit(1)
 	}
 
-	err = s.config.SetUser(name)
+	if user.HashedPassword == "" {
+		// Accounts registered before passwords existed can't be claimed by
+		// whoever logs in first, an operator sets their password.
+		return fmt.Errorf("The account %s has no password yet, ask the operator of the database to set one\n", user.Name)
+	}
+	password, err := promptPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if !checkPassword(user, password) {
+		return fmt.Errorf("Invalid username or password\n")
+	}
+
+	err = login(s, user)
 	if err != nil {
-		return fmt.Errorf("Error while registering your username: %s\n", err)
+		return err
 	}
-	fmt.Printf("Hello %s, you're now logged in", user.Name)
+	fmt.Printf("Hello %s, you're now logged in\n", user.Name)
 	return nil
 }
 
@@ -125,29 +194,56 @@ func handlerRegister(s *state, cmd command) error {
 	}
 	name := cmd.args[0]
 
-	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      name,
+	password, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.db.CreateUser(s.ctx, database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		Name:           name,
+		HashedPassword: hashedPassword,
 	})
 	if err != nil {
 		fmt.Printf("Error occurred while creating user: %s\n", err)
 		os.Exit(1)
 	}
 
-	err = s.config.SetUser(name)
+	err = login(s, user)
 	if err != nil {
-		return fmt.Errorf("Error while registering your username: %s\n", err)
+		return err
 	}
 
-	fmt.Printf("The user '%v' was created successfully\n", user)
+	fmt.Printf("The user '%s' was created successfully\n", user.Name)
 
 	return nil
 }
 
+func handlerLogout(s *state, cmd command) error {
+	if s.config.SessionToken == "" {
+		fmt.Printf("You're not logged in\n")
+		return nil
+	}
+	err := s.db.DeleteSession(s.ctx, hashToken(s.config.SessionToken))
+	if err != nil {
+		return err
+	}
+	err = s.config.ClearSession()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("You're now logged out\n")
+	return nil
+}
+
 func handlerReset(s *state, cmd command) error {
-	err := s.db.DeleteAllUsers(context.Background())
+	err := s.db.DeleteAllUsers(s.ctx)
 	if err != nil {
 		fmt.Printf("Could not reset users: %s\n", err)
 		os.Exit(1)
@@ -156,66 +252,127 @@ func handlerReset(s *state, cmd command) error {
 }
 
 func handlerUsers(s *state, cmd command) error {
-	users, err := s.db.GetUsers(context.Background())
-	currUser := s.config.CurrentUserName
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := formatFlag(fs)
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	users, err := s.db.GetUsers(s.ctx)
 	if err != nil {
 		fmt.Printf("Could not get users: %s\n", err)
 		os.Exit(1)
 	}
 
+	// Being logged out just means no user is marked.
+	currUser, _ := authenticate(s.ctx, s, currentToken(s), scopeRead)
+
+	list := output.NewList("id", "name", "created_at", "current")
 	for _, u := range users {
-		if u.Name == currUser {
-			fmt.Printf("* %s (current)\n", u.Name)
-		} else {
-			fmt.Printf("* %s\n", u.Name)
-		}
+		list.Append(u.ID, u.Name, u.CreatedAt, u.ID == currUser.ID)
 	}
-	return nil
+	return output.Write(os.Stdout, *format, list)
 }
 
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) < 1 {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 1, "number of feeds fetched in parallel")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
 		return fmt.Errorf("A time between request like (1s, 1m, 1h) is required\n")
 	}
-	time_between_reqs := cmd.args[0]
+	time_between_reqs := args[0]
 
 	timeBetweenRequests, err := time.ParseDuration(time_between_reqs)
 	if err != nil {
 		return fmt.Errorf("Error parsing time duration\n")
 	}
-	fmt.Printf("Collecting feeds every %v\n", timeBetweenRequests)
+	if *concurrency < 1 {
+		return fmt.Errorf("Concurrency must be at least 1\n")
+	}
+	fmt.Printf("Collecting feeds every %v with %d worker(s)\n", timeBetweenRequests, *concurrency)
 
+	var stats scrapeStats
 	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+	defer ticker.Stop()
+	for {
+		scrapeFeeds(s, *concurrency, timeBetweenRequests, &stats)
+
+		select {
+		case <-s.ctx.Done():
+			fmt.Printf("Shutting down: %s\n", &stats)
+			return nil
+		case <-ticker.C:
+		}
 	}
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
 	if len(cmd.args) < 1 {
-		return fmt.Errorf("A name is required\n")
+		return fmt.Errorf("An url is required\n")
 	}
 
-	if len(cmd.args) < 2 {
-		return fmt.Errorf("An url is required\n")
+	// The name is optional: addfeed <url> or addfeed <name> <url>
+	name := ""
+	rawURL := cmd.args[0]
+	if len(cmd.args) > 1 {
+		name = cmd.args[0]
+		rawURL = cmd.args[1]
 	}
 
-	name := cmd.args[0]
-	url := cmd.args[1]
+	url, err := urlnorm.Clean(rawURL)
+	if err != nil {
+		return err
+	}
+	url, res, validators, err := fetchNewFeed(s, url)
+	if err != nil {
+		return err
+	}
+	canonicalUrl, err := urlnorm.Key(url)
+	if err != nil {
+		return err
+	}
 
-	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      name,
-		Url:       url,
-		UserID:    user.ID,
-	})
+	if name == "" {
+		name = strings.TrimSpace(res.Channel.Title)
+	}
+	if name == "" {
+		return fmt.Errorf("The feed has no title, a name is required\n")
+	}
+
+	params := database.CreateFeedParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         name,
+		Url:          url,
+		CanonicalUrl: canonicalUrl,
+		UserID:       user.ID,
+		Description:  strings.TrimSpace(res.Channel.Description),
+		SiteUrl:      strings.TrimSpace(res.Channel.Link),
+	}
+	feed, err := s.db.CreateFeed(s.ctx, params)
+
+	// Feed names are unique, tell homonyms apart with their url like
+	// import-opml does.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "feeds_name_key" {
+		params.Name = fmt.Sprintf("%s (%s)", name, canonicalUrl)
+		feed, err = s.db.CreateFeed(s.ctx, params)
+	}
+	if errors.As(err, &pqErr) && pqErr.Constraint == "feeds_canonical_url_key" {
+		return fmt.Errorf("The feed %s already exists, use 'gator follow %s' instead\n", url, url)
+	}
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	_, err = s.db.CreateFeedFollow(s.ctx, database.CreateFeedFollowParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -226,35 +383,133 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 		return err
 	}
 
-	fmt.Printf("%v\n", feed)
+	// The feed was just downloaded, store its posts right away instead of
+	// waiting for the aggregator.
+	err = s.db.MarkFeedFetched(s.ctx, database.MarkFeedFetchedParams{
+		LastFetchedAt: time.Now(),
+		ID:            feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	err = recordFeedFetchSuccess(s.ctx, s, feed)
+	if err != nil {
+		return err
+	}
+	created, _, _, failed := storePosts(s.ctx, s, feed, res.Channel.Item)
+	if failed > 0 {
+		// agg downloads the feed again and retries them.
+		fmt.Printf("%d post(s) could not be stored, they will be retried by agg\n", failed)
+	} else {
+		err = storeCacheValidators(s.ctx, s, feed, validators)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Feed '%s' (%s) added with %d post(s)\n", feed.Name, feed.Url, created)
 
 	return nil
 }
 
 func handlerFeeds(s *state, cmd command) error {
-	feeds, err := s.db.GetFeeds(context.Background())
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := formatFlag(fs)
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	feeds, err := s.db.GetFeeds(s.ctx)
 	if err != nil {
 		fmt.Printf("Could not get feeds: %s\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("%s\n", feeds)
+	list := output.NewList("id", "name", "url", "site_url", "added_by")
+	for _, feed := range feeds {
+		list.Append(feed.ID, feed.Name, feed.Url, feed.SiteUrl, feed.UserName)
+	}
+	return output.Write(os.Stdout, *format, list)
+}
+
+func handlerFeedStatus(s *state, cmd command) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	reset := fs.String("reset", "", "url of a feed to re-enable and clear the errors of")
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
 
+	if *reset != "" {
+		feed, err := getFeedByUrl(s.ctx, s, *reset)
+		if err != nil {
+			return err
+		}
+		err = s.db.ResetFeedFetchFailures(s.ctx, feed.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Feed %s was reset and will be fetched on the next run\n", feed.Url)
+		return nil
+	}
+
+	feeds, err := s.db.GetFeedStatuses(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, feed := range feeds {
+		fmt.Printf("* %s (%s)\n", feed.Name, feed.Url)
+		fmt.Printf("    status:       %s\n", feedStatus(feed))
+		fmt.Printf("    last fetch:   %s\n", formatNullTime(feed.LastFetchedAt))
+		fmt.Printf("    last success: %s\n", formatNullTime(feed.LastSucceededAt))
+		if feed.LastError != "" {
+			fmt.Printf("    last error:   %s\n", feed.LastError)
+		}
+	}
 	return nil
 }
 
+func feedStatus(feed database.Feed) string {
+	switch {
+	case feed.DisabledAt.Valid:
+		return fmt.Sprintf("disabled since %s after %d consecutive failures", formatNullTime(feed.DisabledAt), feed.ConsecutiveFailures)
+	case feed.ConsecutiveFailures > 0:
+		return fmt.Sprintf("failing, %d consecutive failures, retrying after %s", feed.ConsecutiveFailures, formatNullTime(feed.RetryAfter))
+	case !feed.LastFetchedAt.Valid:
+		return "never fetched"
+	default:
+		return "ok"
+	}
+}
+
+// nullTime turns t into a value for the output package, nil when not set.
+func nullTime(t sql.NullTime) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "never"
+	}
+	return t.Time.Local().Format(time.DateTime)
+}
+
 func handlerFollow(s *state, cmd command, user database.User) error {
 	if len(cmd.args) < 1 {
 		return fmt.Errorf("A url is required\n")
 	}
 
-	url := cmd.args[0]
-	feed, err := s.db.GetFeedByUrl(context.Background(), url)
+	feed, err := getFeedByUrl(s.ctx, s, cmd.args[0])
 	if err != nil {
 		return err
 	}
 
-	feedFollow, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	feedFollow, err := s.db.CreateFeedFollow(s.ctx, database.CreateFeedFollowParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -270,13 +525,105 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 }
 
 func handlerFollowing(s *state, cmd command, user database.User) error {
-	feedFollows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	format := formatFlag(fs)
+	_, err := parseFlags(fs, cmd.args)
 	if err != nil {
 		return err
 	}
 
+	feedFollows, err := s.db.GetFeedFollowsForUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	list := output.NewList("feed_id", "name", "url", "site_url", "folder", "followed_at")
 	for _, feedFollow := range feedFollows {
-		fmt.Printf("- %s\n", feedFollow.FeedName)
+		list.Append(feedFollow.FeedID, feedFollow.FeedName, feedFollow.FeedUrl, feedFollow.FeedSiteUrl, feedFollow.Folder, feedFollow.CreatedAt)
+	}
+	return output.Write(os.Stdout, *format, list)
+}
+
+func handlerStar(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	note := fs.String("note", "", "note to keep with the starred post")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("A post id is required\n")
+	}
+	postID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid post id %s\n", args[0])
+	}
+
+	now := time.Now()
+	starred, err := s.db.StarPost(s.ctx, database.StarPostParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Note:      *note,
+		PostID:    postID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Post %s not found\n", postID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Starred %s\n", starred.Title)
+	return nil
+}
+
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("A post id is required\n")
+	}
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid post id %s\n", cmd.args[0])
+	}
+
+	count, err := s.db.UnstarPost(s.ctx, database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: uuid.NullUUID{UUID: postID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("Post %s is not starred\n", postID)
+	}
+	fmt.Printf("Post %s unstarred\n", postID)
+	return nil
+}
+
+// handlerStarred lists the starred posts from their snapshot, so they are
+// still listed once the post or its feed is gone. Those are identified by
+// the id of the star itself.
+func handlerStarred(s *state, cmd command, user database.User) error {
+	starred, err := s.db.GetStarredPostsForUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(starred) == 0 {
+		fmt.Printf("No starred posts\n")
+		return nil
+	}
+
+	for _, post := range starred {
+		id := post.ID
+		if post.PostID.Valid {
+			id = post.PostID.UUID
+		}
+		fmt.Printf("%s  %s  %s\n", id, post.PublishedAt.Format(time.DateOnly), post.Title)
+		fmt.Printf("  %s - %s\n", post.FeedName, post.Url)
+		if post.Note != "" {
+			fmt.Printf("  Note: %s\n", post.Note)
+		}
 	}
 	return nil
 }
@@ -286,14 +633,13 @@ func handlerUnFollow(s *state, cmd command, user database.User) error {
 		return fmt.Errorf("A url is required\n")
 	}
 
-	url := cmd.args[0]
-	feed, err := s.db.GetFeedByUrl(context.Background(), url)
+	feed, err := getFeedByUrl(s.ctx, s, cmd.args[0])
 	if err != nil {
 		return err
 	}
-	err = s.db.DeleteFeedFollowsForUser(context.Background(), database.DeleteFeedFollowsForUserParams{
-		UserID: user.ID,
-		Url:    feed.Url,
+	err = s.db.DeleteFeedFollowsForUser(s.ctx, database.DeleteFeedFollowsForUserParams{
+		UserID:       user.ID,
+		CanonicalUrl: feed.CanonicalUrl,
 	})
 	if err != nil {
 		return err
@@ -301,30 +647,208 @@ func handlerUnFollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+// handlerBrowse lists the posts of the feeds the user follows, newest first.
+// Pages are keyed on the last post shown: --after picks up right after it,
+// while --page skips whole pages and is only stable as long as no new posts
+// come in.
 func handlerBrowse(s *state, cmd command, user database.User) error {
-	limit := int32(10)
-	if len(cmd.args) > 1 {
-		parsedInt64, err := strconv.ParseInt(cmd.args[0], 10, 32)
-		if err != nil {
+	var filters postFilters
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.BoolVar(&filters.all, "all", false, "show read posts too")
+	fs.BoolVar(&filters.onlyRead, "read", false, "show read posts only")
+	fs.StringVar(&filters.feedURL, "feed", "", "only show the posts of this feed")
+	fs.StringVar(&filters.since, "since", "", "only show posts published on or after this date")
+	fs.StringVar(&filters.until, "until", "", "only show posts published on or before this date")
+	fs.StringVar(&filters.after, "after", "", "show the posts that come after this post id")
+	fs.IntVar(&filters.page, "page", 1, "page of posts to show")
+	format := formatFlag(fs)
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	filters.limit = 10
+	if len(args) > 0 {
+		parsedInt64, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil || parsedInt64 < 1 {
 			return fmt.Errorf("Could not parse limit arguments")
 		}
+		filters.limit = int32(parsedInt64)
+	}
 
-		num := int32(parsedInt64)
-		limit = num
+	params, err := filters.params(s.ctx, s, user)
+	if err != nil {
+		return err
+	}
+	posts, err := s.db.GetPostsForUser(s.ctx, params)
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 && *format == output.Table {
+		fmt.Printf("No posts to show\n")
+		return nil
 	}
 
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+	list := output.NewList("id", "published_at", "read", "feed", "title", "url")
+	for _, post := range posts {
+		list.Append(post.ID, post.PublishedAt, post.ReadAt.Valid, post.FeedName, post.Title, post.Url)
+	}
+	err = output.Write(os.Stdout, *format, list)
+	if err != nil {
+		return err
+	}
+	if len(posts) == int(filters.limit) && *format == output.Table {
+		fmt.Printf("\nMore posts: add --after %s\n", posts[len(posts)-1].ID)
+	}
+
+	return nil
+}
+
+// postFilters select the posts listed by browse and by the API.
+type postFilters struct {
+	all      bool
+	onlyRead bool
+	feedURL  string
+	since    string
+	until    string
+	after    string
+	page     int
+	limit    int32
+}
+
+func (f postFilters) params(ctx context.Context, s *state, user database.User) (database.GetPostsForUserParams, error) {
+	if f.page < 1 {
+		return database.GetPostsForUserParams{}, fmt.Errorf("Invalid page %d\n", f.page)
+	}
+	// The offset is an int32, a large page would overflow it.
+	offset := int64(f.page-1) * int64(f.limit)
+	if offset > math.MaxInt32 {
+		return database.GetPostsForUserParams{}, fmt.Errorf("Page %d is out of range\n", f.page)
+	}
+
+	params := database.GetPostsForUserParams{
 		UserID: user.ID,
-		Limit:  limit,
+		Read:   sql.NullBool{Bool: f.onlyRead, Valid: !f.all},
+		Limit:  f.limit,
+		Offset: int32(offset),
+	}
+	if f.feedURL != "" {
+		feed, err := getFeedByUrl(ctx, s, f.feedURL)
+		if err != nil {
+			return params, fmt.Errorf("Feed %s not found\n", f.feedURL)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if f.since != "" {
+		date, err := parseDate(f.since)
+		if err != nil {
+			return params, err
+		}
+		params.Since = sql.NullTime{Time: date, Valid: true}
+	}
+	if f.until != "" {
+		date, err := parseDate(f.until)
+		if err != nil {
+			return params, err
+		}
+		// A day includes everything published during it.
+		if len(f.until) == len(time.DateOnly) {
+			date = date.AddDate(0, 0, 1)
+		}
+		params.Until = sql.NullTime{Time: date, Valid: true}
+	}
+	if f.after != "" {
+		afterID, err := uuid.Parse(f.after)
+		if err != nil {
+			return params, fmt.Errorf("Invalid post id %s\n", f.after)
+		}
+		params.After = uuid.NullUUID{UUID: afterID, Valid: true}
+	}
+	return params, nil
+}
+
+func handlerRead(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("A post id is required\n")
+	}
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid post id %s\n", cmd.args[0])
+	}
+
+	err = s.db.MarkPostRead(s.ctx, database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		ReadAt: time.Now(),
 	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "post_reads_post_id_fkey" {
+		return fmt.Errorf("Post %s not found\n", postID)
+	}
 	if err != nil {
 		return err
 	}
+	fmt.Printf("Post %s marked as read\n", postID)
+	return nil
+}
 
-	for _, post := range posts {
-		fmt.Print(post)
+func handlerUnread(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("A post id is required\n")
+	}
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid post id %s\n", cmd.args[0])
 	}
 
+	count, err := s.db.MarkPostUnread(s.ctx, database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Printf("Post %s was not read\n", postID)
+		return nil
+	}
+	fmt.Printf("Post %s marked as unread\n", postID)
+	return nil
+}
+
+func handlerMarkAllRead(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	feedURL := fs.String("feed", "", "only mark the posts of this feed")
+	before := fs.String("before", "", "only mark the posts published before this date")
+	_, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	params := database.MarkPostsReadParams{
+		ReadAt: time.Now(),
+		UserID: user.ID,
+	}
+	if *feedURL != "" {
+		feed, err := getFeedByUrl(s.ctx, s, *feedURL)
+		if err != nil {
+			return fmt.Errorf("Feed %s not found\n", *feedURL)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if *before != "" {
+		date, err := parseDate(*before)
+		if err != nil {
+			return err
+		}
+		params.Before = sql.NullTime{Time: date, Valid: true}
+	}
+
+	count, err := s.db.MarkPostsRead(s.ctx, params)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Marked %d post(s) as read\n", count)
 	return nil
 }
 
@@ -341,65 +865,334 @@ func (c *commands) register(name string, f commandHandler) {
 	c.handlers[name] = f
 }
 
-func scrapeFeeds(s *state) error {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// fetchNewFeed fetches the feed to add for rawURL and returns its url. When
+// rawURL is a web page rather than a feed, the feeds it links to are
+// discovered and the user picks one of them if there are several.
+func fetchNewFeed(s *state, rawURL string) (string, *rss.RSSFeed, rss.CacheValidators, error) {
+	res, validators, err := s.fetcher.FetchFeedConditional(s.ctx, rawURL, rss.CacheValidators{})
+	if err == nil {
+		return rawURL, res, validators, nil
+	}
+	if !errors.Is(err, rss.ErrHTMLPage) {
+		return "", nil, validators, fmt.Errorf("%s is not a valid feed: %w", rawURL, err)
+	}
+
+	fmt.Printf("%s is a web page, looking for its feeds...\n", rawURL)
+	feeds, err := s.fetcher.DiscoverFeeds(s.ctx, rawURL)
 	if err != nil {
-		return err
+		return "", nil, validators, err
+	}
+	if len(feeds) == 0 {
+		return "", nil, validators, fmt.Errorf("No feed found on %s", rawURL)
 	}
 
-	err = s.db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
-		LastFetchedAt: time.Now(),
-		ID:            feed.ID,
-	})
+	choice := 0
+	if len(feeds) > 1 {
+		fmt.Printf("Found %d feeds:\n", len(feeds))
+		for idx, feed := range feeds {
+			fmt.Printf("  %d. %s %s\n", idx+1, feed.URL, feed.Title)
+		}
+		choice, err = promptChoice(len(feeds))
+		if err != nil {
+			return "", nil, validators, err
+		}
+	}
+
+	fmt.Printf("Using feed %s\n", feeds[choice].URL)
+	feedURL, err := urlnorm.Clean(feeds[choice].URL)
 	if err != nil {
-		return err
+		return "", nil, validators, err
+	}
+	res, validators, err = s.fetcher.FetchFeedConditional(s.ctx, feedURL, rss.CacheValidators{})
+	if err != nil {
+		return "", nil, validators, fmt.Errorf("%s is not a valid feed: %w", feedURL, err)
+	}
+	return feedURL, res, validators, nil
+}
+
+// promptChoice asks the user to pick a number between 1 and n and returns
+// the matching zero-based index.
+func promptChoice(n int) (int, error) {
+	for {
+		fmt.Printf("Pick one [1-%d]: ", n)
+		line, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("No feed picked")
+		}
+		choice, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && choice >= 1 && choice <= n {
+			return choice - 1, nil
+		}
 	}
+}
 
-	feed, err = s.db.GetFeedByUrl(context.Background(), feed.Url)
+// getFeedByUrl looks a feed up by the canonical form of rawURL, so that any
+// equivalent spelling of the url matches.
+func getFeedByUrl(ctx context.Context, s *state, rawURL string) (database.Feed, error) {
+	canonicalUrl, err := urlnorm.Key(rawURL)
 	if err != nil {
-		return err
+		return database.Feed{}, err
 	}
+	return s.db.GetFeedByUrl(ctx, canonicalUrl)
+}
 
-	res, err := rss.FetchFeed(context.Background(), feed.Url)
+// parseDate parses a date given on the command line, either as a day in the
+// local time zone or as an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err == nil {
+		return date, nil
+	}
+	date, err = time.Parse(time.RFC3339, value)
 	if err != nil {
-		fmt.Printf("Error occured while fetching url: %s\n", err)
-		os.Exit(1)
+		return time.Time{}, fmt.Errorf("Invalid date %s, expected YYYY-MM-DD or an RFC 3339 timestamp\n", value)
 	}
+	return date, nil
+}
 
-	for _, post := range res.Channel.Item {
+// formatFlag defines the --format flag of the listing commands.
+func formatFlag(fs *flag.FlagSet) *output.Format {
+	format := output.Table
+	fs.Var(&format, "format", "output format: table, json, csv or yaml")
+	return &format
+}
 
-		pubDate, err := time.Parse(time.RFC1123, post.PubDate)
+// parseFlags parses args with fs and returns the positional arguments. Unlike
+// fs.Parse, flags may appear before or after the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
 		if err != nil {
-			fmt.Printf("Could not parse post %s pub date, skipping. Error: %v\n", post.Title, err) // <--- IMPROVE ERROR MESSAGE
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// scrapeStats counts what the aggregator did, for the summary printed when it
+// shuts down.
+type scrapeStats struct {
+	feeds        atomic.Int64
+	notModified  atomic.Int64
+	failures     atomic.Int64
+	newPosts     atomic.Int64
+	updatedPosts atomic.Int64
+}
+
+func (st *scrapeStats) String() string {
+	return fmt.Sprintf("fetched %d feed(s) (%d not modified, %d failed), stored %d new and %d updated post(s)",
+		st.feeds.Load(), st.notModified.Load(), st.failures.Load(), st.newPosts.Load(), st.updatedPosts.Load())
+}
+
+// scrapeFeeds runs a pool of workers that keep claiming feeds which haven't
+// been fetched for interval. Claims are atomic and staleness doesn't depend on
+// when a process started its round, so several agg processes share the work
+// of the same database rather than each fetching every feed. Once s.ctx is
+// cancelled the workers finish the feed they are working on and stop claiming
+// new ones.
+func scrapeFeeds(s *state, concurrency int, interval time.Duration, stats *scrapeStats) {
+	staleBefore := time.Now().Add(-interval)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s.ctx.Err() == nil {
+				feed, err := s.db.ClaimNextFeedToFetch(s.ctx, database.ClaimNextFeedToFetchParams{
+					FetchedAt:   time.Now(),
+					StaleBefore: staleBefore,
+				})
+				if errors.Is(err, sql.ErrNoRows) || s.ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					fmt.Printf("Could not claim next feed to fetch: %s\n", err)
+					return
+				}
+
+				err = scrapeFeed(s, feed, stats)
+				if err != nil && s.ctx.Err() == nil {
+					stats.failures.Add(1)
+					fmt.Printf("Could not scrape feed %s: %s\n", feed.Url, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// scrapeFeed fetches feed and stores its posts. The fetch is aborted when
+// s.ctx is cancelled, but once the feed is downloaded its posts are stored
+// even if a shutdown was requested in the meantime.
+func scrapeFeed(s *state, feed database.Feed, stats *scrapeStats) error {
+	res, validators, err := s.fetcher.FetchFeedConditional(s.ctx, feed.Url, rss.CacheValidators{
+		ETag:         feed.Etag,
+		LastModified: feed.LastModified,
+	})
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+
+	ctx := context.WithoutCancel(s.ctx)
+	stats.feeds.Add(1)
+	if errors.Is(err, rss.ErrNotModified) {
+		stats.notModified.Add(1)
+		fmt.Printf("Feed %s not modified since last fetch\n", feed.Url)
+		return recordFeedFetchSuccess(ctx, s, feed)
+	}
+	if err != nil {
+		recordErr := recordFeedFetchFailure(ctx, s, feed, err)
+		if recordErr != nil {
+			fmt.Printf("Could not record fetch failure of feed %s: %s\n", feed.Url, recordErr)
+		}
+		return err
+	}
+
+	err = recordFeedFetchSuccess(ctx, s, feed)
+	if err != nil {
+		return err
+	}
+
+	created, updated, unchanged, failed := storePosts(ctx, s, feed, res.Channel.Item)
+	stats.newPosts.Add(int64(created))
+	stats.updatedPosts.Add(int64(updated))
+	fmt.Printf("Feed %s: %d new, %d updated, %d unchanged, %d failed post(s)\n", feed.Url, created, updated, unchanged, failed)
+
+	// Keeping the old validators makes the next fetch download the feed
+	// again instead of getting a 304, so the failed posts are retried.
+	if failed > 0 {
+		return nil
+	}
+	return storeCacheValidators(ctx, s, feed, validators)
+}
+
+// storePosts upserts the items of feed and returns how many posts were
+// created, updated, left unchanged and could not be stored.
+func storePosts(ctx context.Context, s *state, feed database.Feed, items []rss.RSSItem) (created, updated, unchanged, failed int) {
+	fetchedAt := time.Now()
+	for _, post := range items {
+		guid := post.Identity()
+		if guid == "" {
+			fmt.Printf("Skipping post '%s', it has neither a guid nor a link\n", post.Title)
 			continue
 		}
-		_, err = s.db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       post.Title,
-			Url:         post.Link,
-			Description: post.Description,
-			PublishedAt: pubDate,
-			FeedID:      feed.ID,
-		})
 
-		if err != nil {
-			if strings.Contains(err.Error(), "posts_url_key") {
-				fmt.Printf("Skipping post '%s', already exists\n", post.Title)
+		pubDate, estimated := post.PublishedAt(fetchedAt)
+		if estimated {
+			fmt.Printf("Could not parse pub date '%s' of post '%s', using fetch time instead\n", post.PubDate, post.Title)
+		}
+		params := database.UpsertPostParams{
+			ID:                   uuid.New(),
+			CreatedAt:            fetchedAt,
+			UpdatedAt:            fetchedAt,
+			Title:                post.Title,
+			Url:                  post.CleanLink(),
+			Description:          post.Description,
+			PublishedAt:          pubDate,
+			PublishedAtEstimated: estimated,
+			FeedID:               feed.ID,
+			Guid:                 guid,
+		}
+		// Posts stored before the GUID of items was used are keyed on their
+		// link, they take the new key instead of being stored twice.
+		previous := post.PreviousIdentities()
+		if len(previous) > 0 {
+			_, err := s.db.AdoptPost(ctx, database.AdoptPostParams{
+				Guid:          guid,
+				FeedID:        feed.ID,
+				PreviousGuids: previous,
+				Urls:          post.LinkForms(),
+			})
+			if err != nil {
+				failed++
+				fmt.Printf("Could not store post %s:  %v\n", post.Title, err)
 				continue
 			}
-			fmt.Printf("Could not create post %s:  %v\n", post.Title, err)
+		}
+
+		storedID, err := s.db.UpsertPost(ctx, params)
+
+		// The upsert only returns a row when the post was inserted or its
+		// content changed, and an inserted post keeps the id we generated.
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			unchanged++
+		case err != nil:
+			failed++
+			fmt.Printf("Could not store post %s:  %v\n", post.Title, err)
+		case storedID == params.ID:
+			created++
+		default:
+			updated++
 		}
 	}
+	return created, updated, unchanged, failed
+}
 
-	return nil
+func storeCacheValidators(ctx context.Context, s *state, feed database.Feed, validators rss.CacheValidators) error {
+	if validators.ETag == feed.Etag && validators.LastModified == feed.LastModified {
+		return nil
+	}
+	return s.db.UpdateFeedCacheValidators(ctx, database.UpdateFeedCacheValidatorsParams{
+		ID:           feed.ID,
+		Etag:         validators.ETag,
+		LastModified: validators.LastModified,
+	})
+}
+
+func recordFeedFetchSuccess(ctx context.Context, s *state, feed database.Feed) error {
+	return s.db.RecordFeedFetchSuccess(ctx, database.RecordFeedFetchSuccessParams{
+		ID:              feed.ID,
+		LastSucceededAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+}
+
+// recordFeedFetchFailure stores the fetch error and puts the feed in cooldown.
+// The cooldown doubles with every consecutive failure, and the feed is
+// disabled once it reaches maxFeedFailures or right away when it is gone.
+func recordFeedFetchFailure(ctx context.Context, s *state, feed database.Feed, fetchErr error) error {
+	now := time.Now()
+	failures := feed.ConsecutiveFailures + 1
+
+	params := database.RecordFeedFetchFailureParams{
+		ID:         feed.ID,
+		LastError:  fetchErr.Error(),
+		RetryAfter: sql.NullTime{Time: now.Add(feedBackoff(failures)), Valid: true},
+	}
+	var statusErr *rss.StatusError
+	if errors.As(fetchErr, &statusErr) && statusErr.StatusCode == http.StatusGone {
+		fmt.Printf("Disabling feed %s, the server reports it is gone\n", feed.Url)
+		params.DisabledAt = sql.NullTime{Time: now, Valid: true}
+	} else if failures >= maxFeedFailures {
+		fmt.Printf("Disabling feed %s after %d consecutive failures\n", feed.Url, failures)
+		params.DisabledAt = sql.NullTime{Time: now, Valid: true}
+	}
+	return s.db.RecordFeedFetchFailure(ctx, params)
+}
+
+func feedBackoff(failures int32) time.Duration {
+	backoff := feedBackoffBase
+	for i := int32(1); i < failures && backoff < feedBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, feedBackoffMax)
 }
 
 // Middlewares
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
-		currUser, err := s.db.GetUserByName(context.Background(), s.config.CurrentUserName)
+		scope := scopeWrite
+		if readOnlyCommands[cmd.name] {
+			scope = scopeRead
+		}
+		currUser, err := authenticate(s.ctx, s, currentToken(s), scope)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,33 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetAPITokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: GetUserByAPIToken :one
+SELECT users.*, api_tokens.id AS token_id, api_tokens.scopes AS token_scopes
+FROM api_tokens
+INNER JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = $1
+  AND (api_tokens.expires_at IS NULL OR api_tokens.expires_at > NOW());
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = $2
+WHERE id = $1;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE user_id = $1 AND name = $2;
@@ -23,15 +23,25 @@ INNER JOIN feeds ON feeds.id = inserted_feed_follow.feed_id;
 SELECT 
     feed_follows.*,
     feeds.name AS feed_name,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.url AS feed_url,
+    feeds.site_url AS feed_site_url,
+    feeds.description AS feed_description
 FROM feed_follows
 INNER JOIN users ON users.id = feed_follows.user_id
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1;
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.folder, feeds.name;
 
 -- name: DeleteFeedFollowsForUser :exec
 DELETE FROM feed_follows
 USING feeds
 WHERE feed_follows.feed_id = feeds.id
   AND feed_follows.user_id = $1
-  AND feeds.url = $2;
\ No newline at end of file
+  AND feeds.canonical_url = $2;
+
+-- name: SetFeedFollowFolder :exec
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+WHERE feed_follows.user_id = $1
+  AND feed_follows.feed_id = $2;
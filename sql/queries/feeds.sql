@@ -1,31 +1,66 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, canonical_url, user_id, description, site_url)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8,
+    $9
 )
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name AS user_name FROM feeds
-INNER JOIN users ON users.id = feeds.user_id;
+SELECT feeds.id, feeds.name, feeds.url, feeds.site_url, users.name AS user_name FROM feeds
+INNER JOIN users ON users.id = feeds.user_id
+ORDER BY feeds.name;
 
 -- name: GetFeedByUrl :one
 SELECT * FROM feeds
-WHERE feeds.url = $1;
+WHERE feeds.canonical_url = $1;
+
+-- name: GetFeedStatuses :many
+SELECT * FROM feeds
+ORDER BY disabled_at ASC NULLS FIRST, consecutive_failures DESC, name;
 
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $1, updated_at = $1
 WHERE feeds.id = $2;
 
--- name: GetNextFeedToFetch :one
-SELECT * FROM feeds
-ORDER BY
-    last_fetched_at ASC NULLS FIRST,
-    last_fetched_at ASC
-LIMIT 1;
+-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = sqlc.arg('fetched_at')::timestamptz, updated_at = sqlc.arg('fetched_at')::timestamptz
+WHERE feeds.id = (
+    SELECT id FROM feeds
+    WHERE (last_fetched_at IS NULL OR last_fetched_at < sqlc.arg('stale_before')::timestamptz)
+      AND (retry_after IS NULL OR retry_after <= sqlc.arg('fetched_at')::timestamptz)
+      AND disabled_at IS NULL
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordFeedFetchSuccess :exec
+UPDATE feeds
+SET last_error = '', consecutive_failures = 0, last_succeeded_at = $2, retry_after = NULL
+WHERE feeds.id = $1;
+
+-- name: RecordFeedFetchFailure :exec
+UPDATE feeds
+SET last_error = $2, consecutive_failures = consecutive_failures + 1, retry_after = $3, disabled_at = $4
+WHERE feeds.id = $1;
+
+-- name: ResetFeedFetchFailures :exec
+UPDATE feeds
+SET last_error = '', consecutive_failures = 0, retry_after = NULL, disabled_at = NULL
+WHERE feeds.id = $1;
+
+-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3
+WHERE feeds.id = $1;
@@ -1,5 +1,21 @@
--- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+-- name: AdoptPost :execrows
+UPDATE posts
+SET guid = sqlc.arg('guid')
+WHERE posts.id = (
+    SELECT legacy.id FROM posts AS legacy
+    WHERE legacy.feed_id = sqlc.arg('feed_id')
+      AND legacy.guid = ANY(sqlc.arg('previous_guids')::text[])
+      AND legacy.url = ANY(sqlc.arg('urls')::text[])
+    ORDER BY legacy.created_at, legacy.id
+    LIMIT 1
+)
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS current
+    WHERE current.feed_id = sqlc.arg('feed_id') AND current.guid = sqlc.arg('guid')
+);
+
+-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, published_at_estimated, feed_id, guid)
 VALUES (
     $1,
     $2,
@@ -8,13 +24,81 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10
 )
-RETURNING *;
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+RETURNING id;
 
 -- name: GetPostsForUser :many
-SELECT * FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_estimated, posts.guid, feeds.name AS feed_name, post_reads.read_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = sqlc.arg('user_id')
 INNER JOIN feeds ON feeds.id = posts.feed_id
-WHERE feeds.user_id = $1
-ORDER BY published_at
-LIMIT $2;
\ No newline at end of file
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = sqlc.arg('user_id')
+WHERE (sqlc.narg('read')::boolean IS NULL OR (post_reads.post_id IS NOT NULL) = sqlc.narg('read'))
+  AND (sqlc.narg('feed_id')::uuid IS NULL OR posts.feed_id = sqlc.narg('feed_id'))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR posts.published_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR posts.published_at < sqlc.narg('until'))
+  AND (sqlc.narg('after')::uuid IS NULL OR (posts.published_at, posts.id) < (
+    SELECT after_post.published_at, after_post.id FROM posts AS after_post WHERE after_post.id = sqlc.narg('after')
+  ))
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: SearchPosts :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        posts.description,
+        query,
+        'StartSel=**, StopSel=**, MaxWords=25, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN to_tsquery('english', sqlc.arg('query')) AS query
+WHERE posts.search_vector @@ query
+  AND (
+    sqlc.arg('all_feeds')::boolean
+    OR EXISTS (
+        SELECT 1 FROM feed_follows
+        WHERE feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = sqlc.arg('user_id')
+    )
+  )
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetTimelineForUser :many
+SELECT timeline.id, timeline.updated_at, timeline.title, timeline.url, timeline.description, timeline.published_at, timeline.feed_name, timeline.feed_url
+FROM (
+    SELECT DISTINCT ON (posts.url)
+        posts.id,
+        posts.updated_at,
+        posts.title,
+        posts.url,
+        posts.description,
+        posts.published_at,
+        feeds.name AS feed_name,
+        feeds.url AS feed_url
+    FROM posts
+    INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = $1
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    ORDER BY posts.url, posts.published_at
+) AS timeline
+ORDER BY timeline.published_at DESC, timeline.id DESC
+LIMIT $2;
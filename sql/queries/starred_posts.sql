@@ -0,0 +1,30 @@
+-- name: StarPost :one
+INSERT INTO starred_posts (id, created_at, updated_at, user_id, post_id, feed_name, title, url, description, published_at, note)
+SELECT
+    sqlc.arg('id')::uuid,
+    sqlc.arg('created_at')::timestamptz,
+    sqlc.arg('updated_at')::timestamptz,
+    sqlc.arg('user_id')::uuid,
+    posts.id,
+    feeds.name,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    sqlc.arg('note')::text
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id = sqlc.arg('post_id')
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET note = CASE WHEN EXCLUDED.note = '' THEN starred_posts.note ELSE EXCLUDED.note END,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: UnstarPost :execrows
+DELETE FROM starred_posts
+WHERE user_id = $1 AND (post_id = $2 OR id = $2);
+
+-- name: GetStarredPostsForUser :many
+SELECT * FROM starred_posts
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -1,10 +1,11 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, hashed_password)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
 
@@ -16,4 +17,11 @@ WHERE users.name = $1;
 DELETE FROM users;
 
 -- name: GetUsers :many
-SELECT * FROM users;
\ No newline at end of file
+SELECT * FROM users
+ORDER BY name;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2,
+    updated_at = $3
+WHERE id = $1;
@@ -13,7 +13,7 @@ CREATE INDEX idx_feed_follows_user_id ON feed_follows (user_id);
 CREATE INDEX idx_feed_follows_feed_id ON feed_follows (feed_id);
 
 -- +goose Down
-DROP INDEX idx_feed_follows_feed_id
-DROP INDEX idx_feed_follows_user_id
+DROP INDEX idx_feed_follows_feed_id;
+DROP INDEX idx_feed_follows_user_id;
 
 DROP TABLE feed_follows;
\ No newline at end of file
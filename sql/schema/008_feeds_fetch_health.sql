@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_succeeded_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN retry_after TIMESTAMP WITH TIME ZONE,
+ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN last_error,
+DROP COLUMN consecutive_failures,
+DROP COLUMN last_succeeded_at,
+DROP COLUMN retry_after,
+DROP COLUMN disabled_at;
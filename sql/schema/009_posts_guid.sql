@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN guid TEXT;
+
+UPDATE posts SET guid = url;
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL,
+DROP CONSTRAINT posts_url_key,
+ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+
+CREATE INDEX idx_posts_url ON posts (url);
+
+-- +goose Down
+DROP INDEX idx_posts_url;
+
+-- Posts sharing a url have to go before the url can be unique again.
+DELETE FROM posts AS duplicate
+USING posts AS original
+WHERE duplicate.url = original.url
+  AND (duplicate.created_at, duplicate.id) > (original.created_at, original.id);
+
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_guid_key,
+ADD CONSTRAINT posts_url_key UNIQUE (url),
+DROP COLUMN guid;
@@ -0,0 +1,127 @@
+-- +goose Up
+-- Mirrors urlnorm.Normalize, keep both in sync. Like the code, it is only
+-- used to compare urls, the stored ones are kept as they are.
+-- +goose StatementBegin
+CREATE FUNCTION gator_normalize_url(raw TEXT) RETURNS TEXT AS $$
+DECLARE
+    parts TEXT[];
+    scheme TEXT;
+    host TEXT;
+    path TEXT;
+    query TEXT;
+BEGIN
+    raw := btrim(raw);
+    IF position('://' IN raw) = 0 THEN
+        raw := 'https://' || raw;
+    END IF;
+    parts := regexp_match(raw, '^([A-Za-z][A-Za-z0-9+.-]*)://([^/?#]*)([^?#]*)(\?[^#]*)?');
+    IF parts IS NULL THEN
+        RETURN raw;
+    END IF;
+
+    scheme := lower(parts[1]);
+    host := lower(parts[2]);
+    IF (scheme = 'http' AND host LIKE '%:80') OR (scheme = 'https' AND host LIKE '%:443') THEN
+        host := regexp_replace(host, ':[0-9]+$', '');
+    END IF;
+    path := regexp_replace(parts[3], '/+$', '');
+
+    SELECT string_agg(param, '&' ORDER BY param COLLATE "C") INTO query
+    FROM unnest(string_to_array(substr(coalesce(parts[4], ''), 2), '&')) AS param
+    WHERE param <> ''
+      AND lower(split_part(param, '=', 1)) !~ '^(utm_.*|fbclid|gclid|dclid|msclkid|mc_cid|mc_eid|_hsenc|_hsmi|igshid|yclid)$';
+
+    IF query IS NULL THEN
+        RETURN scheme || '://' || host || path;
+    END IF;
+    RETURN scheme || '://' || host || path || '?' || query;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+-- +goose StatementEnd
+
+-- Mirrors urlnorm.Key.
+-- +goose StatementBegin
+CREATE FUNCTION gator_url_key(raw TEXT) RETURNS TEXT AS $$
+    SELECT regexp_replace(gator_normalize_url(raw), '^[a-z][a-z0-9+.-]*://', '');
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+ALTER TABLE feeds
+ADD COLUMN canonical_url TEXT;
+
+UPDATE feeds SET canonical_url = gator_url_key(url);
+
+-- Merge feeds sharing a canonical url into the oldest one.
+CREATE TEMPORARY TABLE feed_merges AS
+SELECT duplicate.id AS duplicate_id, keeper.id AS keeper_id
+FROM feeds AS duplicate
+INNER JOIN LATERAL (
+    SELECT feeds.id FROM feeds
+    WHERE feeds.canonical_url = duplicate.canonical_url
+    ORDER BY feeds.created_at, feeds.id
+    LIMIT 1
+) AS keeper ON keeper.id <> duplicate.id;
+
+UPDATE feed_follows
+SET feed_id = feed_merges.keeper_id
+FROM feed_merges
+WHERE feed_follows.feed_id = feed_merges.duplicate_id
+  AND feed_follows.id IN (
+      SELECT DISTINCT ON (merges.keeper_id, follows.user_id) follows.id
+      FROM feed_follows AS follows
+      INNER JOIN feed_merges AS merges ON merges.duplicate_id = follows.feed_id
+      ORDER BY merges.keeper_id, follows.user_id, follows.created_at
+  )
+  AND NOT EXISTS (
+      SELECT 1 FROM feed_follows AS kept
+      WHERE kept.feed_id = feed_merges.keeper_id
+        AND kept.user_id = feed_follows.user_id
+  );
+
+UPDATE posts
+SET feed_id = feed_merges.keeper_id
+FROM feed_merges
+WHERE posts.feed_id = feed_merges.duplicate_id
+  AND posts.id IN (
+      SELECT DISTINCT ON (merges.keeper_id, moved.guid) moved.id
+      FROM posts AS moved
+      INNER JOIN feed_merges AS merges ON merges.duplicate_id = moved.feed_id
+      ORDER BY merges.keeper_id, moved.guid, moved.created_at
+  )
+  AND NOT EXISTS (
+      SELECT 1 FROM posts AS kept
+      WHERE kept.feed_id = feed_merges.keeper_id
+        AND kept.guid = posts.guid
+  );
+
+-- Follows and posts that were not moved are duplicates and cascade.
+DELETE FROM feeds
+USING feed_merges
+WHERE feeds.id = feed_merges.duplicate_id;
+
+DROP TABLE feed_merges;
+
+ALTER TABLE feeds
+ALTER COLUMN canonical_url SET NOT NULL,
+ADD CONSTRAINT feeds_canonical_url_key UNIQUE (canonical_url);
+
+-- Posts without a guid were identified by their link, they now are by its
+-- canonical form. Drop the posts that turn out to be duplicates first.
+DELETE FROM posts AS duplicate
+USING posts AS original
+WHERE duplicate.feed_id = original.feed_id
+  AND gator_normalize_url(duplicate.guid) = gator_normalize_url(duplicate.url)
+  AND gator_normalize_url(original.guid) = gator_normalize_url(original.url)
+  AND gator_url_key(duplicate.url) = gator_url_key(original.url)
+  AND (duplicate.created_at, duplicate.id) > (original.created_at, original.id);
+
+UPDATE posts
+SET guid = gator_url_key(url)
+WHERE gator_normalize_url(guid) = gator_normalize_url(url);
+
+DROP FUNCTION gator_url_key(TEXT);
+DROP FUNCTION gator_normalize_url(TEXT);
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN canonical_url;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN description TEXT NOT NULL DEFAULT '',
+ADD COLUMN site_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN description,
+DROP COLUMN site_url;
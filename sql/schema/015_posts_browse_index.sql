@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX posts_feed_id_published_at_idx ON posts (feed_id, published_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX posts_feed_id_published_at_idx;